@@ -0,0 +1,186 @@
+package gforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var AggregateFieldNotFoundError = errors.New("Aggregate field not found")
+
+// AggregateResult is a row of an aggregate query. Fields are looked up case insensitively by
+// alias, grouped field name or exprN for unaliased aggregates.
+type AggregateResult map[string]interface{}
+
+func CountField(field string) string {
+	return "COUNT(" + field + ")"
+}
+
+func CountDistinct(field string) string {
+	return "COUNT_DISTINCT(" + field + ")"
+}
+
+func Sum(field string) string {
+	return "SUM(" + field + ")"
+}
+
+func Avg(field string) string {
+	return "AVG(" + field + ")"
+}
+
+func Min(field string) string {
+	return "MIN(" + field + ")"
+}
+
+func Max(field string) string {
+	return "MAX(" + field + ")"
+}
+
+// Grouping is true on the subtotal rows of field in a ROLLUP or CUBE query
+func Grouping(field string) string {
+	return "GROUPING(" + field + ")"
+}
+
+// As aliases an aggregate expression, e.g. As(Sum("Amount"), "total")
+func As(expression, alias string) string {
+	return expression + " " + alias
+}
+
+// Aggregate runs an aggregate query and returns its rows
+func (f *Force) Aggregate(ctx context.Context, q *SOQLQuery, options ...func(*QueryOptions)) (results []AggregateResult, err error) {
+	query, err := q.Build()
+	if err != nil {
+		return
+	}
+	return f.AggregateSOQL(ctx, query, options...)
+}
+
+// AggregateSOQL runs an aggregate query written as SOQL and returns its rows
+func (f *Force) AggregateSOQL(ctx context.Context, query string, options ...func(*QueryOptions)) (results []AggregateResult, err error) {
+	it := f.NewQueryIterator(ctx, query, options...)
+	defer it.Close()
+	for it.Next() {
+		row := AggregateResult{}
+		for k, v := range it.Record() {
+			if k != "attributes" {
+				row[k] = v
+			}
+		}
+		results = append(results, row)
+	}
+	err = it.Err()
+	return
+}
+
+// CountWhere counts the records of sobject matching where, slices are matched with IN and other values with =
+func (f *Force) CountWhere(ctx context.Context, sobject string, where map[string]interface{}, options ...func(*QueryOptions)) (result int, err error) {
+	return f.CountQuery(ctx, NewSOQLQuery(sobject).Where(whereFromMap(where)...), options...)
+}
+
+// CountQuery runs q as SELECT COUNT() with its WHERE clause and returns the count.
+// The fields, ordering and limits of q are ignored.
+func (f *Force) CountQuery(ctx context.Context, q *SOQLQuery, options ...func(*QueryOptions)) (result int, err error) {
+	count := &SOQLQuery{sobject: q.sobject, fields: []string{"COUNT()"}, where: q.where}
+	query, err := count.Build()
+	if err != nil {
+		return
+	}
+	it := f.NewQueryIterator(ctx, query, options...)
+	defer it.Close()
+	if _, ok := it.Page(); !ok {
+		return 0, fmt.Errorf("Error on count %s: %w", q.sobject, it.Err())
+	}
+	return it.TotalSize(), nil
+}
+
+func (r AggregateResult) lookup(name string) (value interface{}, err error) {
+	if value, ok := r[name]; ok {
+		return value, nil
+	}
+	for k, v := range r {
+		if strings.EqualFold(k, name) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", AggregateFieldNotFoundError, name)
+}
+
+// Expr returns the value of the i-th unaliased aggregate (expr0, expr1...)
+func (r AggregateResult) Expr(i int) interface{} {
+	value, _ := r.lookup(fmt.Sprintf("expr%d", i))
+	return value
+}
+
+// IsNull reports whether name is null or missing, as aggregates over no rows and subtotal groups are
+func (r AggregateResult) IsNull(name string) bool {
+	value, err := r.lookup(name)
+	return err != nil || value == nil
+}
+
+// Int returns a count or an integer sum, null is 0
+func (r AggregateResult) Int(name string) (int64, error) {
+	value, err := r.lookup(name)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	n, err := toFloat(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return int64(n), nil
+}
+
+// Float returns a sum, average, minimum or maximum, null is 0
+func (r AggregateResult) Float(name string) (float64, error) {
+	value, err := r.lookup(name)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	n, err := toFloat(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+// String returns a grouped text field, null is ""
+func (r AggregateResult) String(name string) (string, error) {
+	value, err := r.lookup(name)
+	if err != nil || value == nil {
+		return "", err
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// Time returns a grouped or aggregated date or datetime, null is the zero time
+func (r AggregateResult) Time(name string) (time.Time, error) {
+	value, err := r.lookup(name)
+	if err != nil || value == nil {
+		return time.Time{}, err
+	}
+	t, err := toTime(value)
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// Bool returns a grouped boolean or a GROUPING() value
+func (r AggregateResult) Bool(name string) (bool, error) {
+	value, err := r.lookup(name)
+	if err != nil || value == nil {
+		return false, err
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	}
+	return false, fmt.Errorf("%s: can not decode %T into bool", name, value)
+}
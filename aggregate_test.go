@@ -0,0 +1,31 @@
+package gforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCountQuery(t *testing.T) {
+	var query string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		fmt.Fprint(w, `{"done":true,"totalSize":42,"records":[]}`)
+	})
+
+	count, err := f.CountWhere(context.Background(), "Account", map[string]interface{}{"Type": "Customer"})
+	if err != nil || count != 42 {
+		t.Errorf("count '%v' '%v'", count, err)
+	}
+	if expected := "SELECT COUNT() FROM Account WHERE Type = 'Customer'"; query != expected {
+		t.Errorf("invalid query '%v', expected '%v'", query, expected)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.CountQuery(ctx, NewSOQLQuery("Account").Select("Id")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled count '%v'", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/url"
@@ -19,6 +20,9 @@ import (
 	"source.cloud.google.com/grendene-crm-prod/gforce/keystore"
 )
 
+// JobCertificateAlias is the keystore alias holding the key used to sign the JWT bearer assertion
+const JobCertificateAlias = "job_certificate"
+
 // GetServerAuthorization func
 func GetServerAuthorization(orgID, clientID, userMail, authURL, endpointURL string) (result ForceSession, err error) {
 	token, err := generateNewCertToken(orgID, clientID, authURL, userMail)
@@ -85,8 +89,11 @@ func generateNewCertToken(orgID, clientID, loginURL, userMail string) (accessCod
 
 	password := []byte(os.Getenv("JKS_PASSWORD"))
 	ks := readKeyStore(jksCert, password)
-	entry := ks["job_certificate"]
-	privateKeyEntry := entry.(*keystore.PrivateKeyEntry)
+	entry := ks[JobCertificateAlias]
+	privateKeyEntry, ok := entry.(*keystore.PrivateKeyEntry)
+	if !ok {
+		return accessCode, fmt.Errorf("Keystore of org %s has no private key entry %q", orgID, JobCertificateAlias)
+	}
 	jwtKey, err := x509.ParsePKCS8PrivateKey(privateKeyEntry.PrivateKey)
 	if err != nil {
 		return accessCode, fmt.Errorf("Error on Parse Private Key: %w", err)
@@ -144,3 +151,56 @@ func readKeyStore(content []byte, password []byte) keystore.KeyStore {
 
 	return keyStore
 }
+
+// GenerateJobKeyStore creates a key pair and self-signed certificate for the Connected App used by
+// GetServerAuthorization. The JKS, protected by password and holding the JobCertificateAlias entry,
+// is written into jks and the certificate to upload to Salesforce is written into certPEM as PEM.
+func GenerateJobKeyStore(jks, certPEM io.Writer, password []byte, opts keystore.CertificateOptions) (info keystore.EntryInfo, err error) {
+	entry, err := keystore.GenerateSelfSigned(opts)
+	if err != nil {
+		return info, fmt.Errorf("Error on generate certificate: %w", err)
+	}
+
+	ks := keystore.KeyStore{JobCertificateAlias: entry}
+	if err = keystore.Encode(jks, ks, password); err != nil {
+		return info, fmt.Errorf("Error on encode keystore: %w", err)
+	}
+
+	if err = keystore.EncodeCertificatePEM(certPEM, entry); err != nil {
+		return info, fmt.Errorf("Error on encode certificate: %w", err)
+	}
+
+	return ks.Info(JobCertificateAlias)
+}
+
+// CheckJobCertificate decodes a JKS in the GetServerAuthorization layout and reports whether its
+// certificate expires within the given duration.
+func CheckJobCertificate(content, password []byte, within time.Duration) (info keystore.EntryInfo, expiring bool, err error) {
+	ks, err := keystore.Decode(bytes.NewReader(content), password)
+	if err != nil {
+		return info, false, fmt.Errorf("Error on decode keystore: %w", err)
+	}
+
+	if _, ok := ks[JobCertificateAlias].(*keystore.PrivateKeyEntry); !ok {
+		return info, false, fmt.Errorf("Keystore has no private key entry %q", JobCertificateAlias)
+	}
+
+	info, err = ks.Info(JobCertificateAlias)
+	if err != nil {
+		return
+	}
+
+	expiring = info.NotAfter.Before(time.Now().Add(within))
+
+	return
+}
+
+// CheckOrgJobCertificate runs CheckJobCertificate against the JKS stored for orgID in the bucket.
+func CheckOrgJobCertificate(orgID string, within time.Duration) (info keystore.EntryInfo, expiring bool, err error) {
+	jksCert, err := getJKSFile(orgID)
+	if err != nil {
+		return info, false, err
+	}
+
+	return CheckJobCertificate(jksCert, []byte(os.Getenv("JKS_PASSWORD")), within)
+}
@@ -0,0 +1,379 @@
+// Command gforce-keystore manages the JKS files used by the JWT bearer flow (cert.jks).
+//
+// Usage:
+//
+//	gforce-keystore list   -keystore cert.jks
+//	gforce-keystore import -keystore cert.jks -alias job_certificate -key key.pem -chain chain.pem
+//	gforce-keystore import -keystore cert.jks -alias ca -cert ca.pem
+//	gforce-keystore export -keystore cert.jks -alias job_certificate -out job.pem
+//	gforce-keystore passwd -keystore cert.jks
+//	gforce-keystore delete -keystore cert.jks -alias job_certificate
+//	gforce-keystore bootstrap -keystore cert.jks -cert job_certificate.crt -cn my-org-job -days 730
+//	gforce-keystore check  -keystore cert.jks -days 30
+//
+// The keystore password is read from -storepass or from the JKS_PASSWORD environment variable.
+// passwd reads the new password from -newpass or from the JKS_NEW_PASSWORD environment variable.
+// Everything runs offline, no Salesforce or Google Cloud Storage access is required.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"source.cloud.google.com/grendene-crm-prod/gforce"
+	"source.cloud.google.com/grendene-crm-prod/gforce/keystore"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "list entries with type, creation time, subject and expiry", runList},
+		{"import", "import a PEM private key and chain, or a trusted PEM certificate", runImport},
+		{"export", "export an entry to PEM", runExport},
+		{"passwd", "change the keystore password", runPasswd},
+		{"delete", "delete an entry", runDelete},
+		{"bootstrap", "generate a key, a self-signed certificate and a JKS for the JWT flow", runBootstrap},
+		{"check", "fail when the JWT flow certificate expires within the given days", runCheck},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gforce-keystore %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gforce-keystore <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+func newFlagSet(name string) (fs *flag.FlagSet, path, storepass *string) {
+	fs = flag.NewFlagSet(name, flag.ExitOnError)
+	path = fs.String("keystore", "cert.jks", "path of the JKS file")
+	storepass = fs.String("storepass", "", "keystore password (default $JKS_PASSWORD)")
+	return
+}
+
+func password(flagValue, env string) ([]byte, error) {
+	if flagValue != "" {
+		return []byte(flagValue), nil
+	}
+	if value := os.Getenv(env); value != "" {
+		return []byte(value), nil
+	}
+	return nil, fmt.Errorf("no password given, use the flag or set %s", env)
+}
+
+func readKeyStore(path string, password []byte) (keystore.KeyStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ks, err := keystore.Decode(f, password)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return ks, nil
+}
+
+// writeKeyStore encodes into a temporary file next to path and renames it over the original,
+// so an interrupted write never leaves a truncated keystore behind
+func writeKeyStore(path string, ks keystore.KeyStore, password []byte) error {
+	var buf bytes.Buffer
+	if err := keystore.Encode(&buf, ks, password); err != nil {
+		return fmt.Errorf("encode %s: %w", path, err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func runList(args []string) error {
+	fs, path, storepass := newFlagSet("list")
+	fs.Parse(args)
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+	ks, err := readKeyStore(*path, pass)
+	if err != nil {
+		return err
+	}
+	infos, err := ks.List()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tTYPE\tCREATED\tSUBJECT\tEXPIRES")
+	for _, info := range infos {
+		expires := "-"
+		if !info.NotAfter.IsZero() {
+			expires = info.NotAfter.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", info.Alias, info.Type, info.CreationTime.Format(time.RFC3339), info.Subject, expires)
+	}
+	return w.Flush()
+}
+
+func runImport(args []string) error {
+	fs, path, storepass := newFlagSet("import")
+	alias := fs.String("alias", "", "alias of the new entry")
+	keyFile := fs.String("key", "", "PEM file holding the private key")
+	chainFile := fs.String("chain", "", "PEM file holding the certificate chain, leaf first (default -key)")
+	certFile := fs.String("cert", "", "PEM file holding a trusted certificate")
+	force := fs.Bool("force", false, "replace an existing entry with the same alias")
+	create := fs.Bool("create", false, "create the keystore when it does not exist")
+	fs.Parse(args)
+
+	if *alias == "" {
+		return fmt.Errorf("-alias is required")
+	}
+	if (*keyFile == "") == (*certFile == "") {
+		return fmt.Errorf("exactly one of -key or -cert is required")
+	}
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+
+	ks, err := readKeyStore(*path, pass)
+	if os.IsNotExist(err) && *create {
+		ks, err = keystore.KeyStore{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := ks[*alias]; ok && !*force {
+		return fmt.Errorf("alias %q already exists, use -force to replace it", *alias)
+	}
+
+	if *keyFile != "" {
+		keyPEM, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		chainPEM := keyPEM
+		if *chainFile != "" {
+			if chainPEM, err = ioutil.ReadFile(*chainFile); err != nil {
+				return err
+			}
+		}
+		entry, err := keystore.NewPrivateKeyEntryFromPEM(keyPEM, chainPEM, time.Now())
+		if err != nil {
+			return err
+		}
+		ks[*alias] = entry
+	} else {
+		certPEM, err := ioutil.ReadFile(*certFile)
+		if err != nil {
+			return err
+		}
+		entry, err := keystore.NewTrustedCertificateEntryFromPEM(certPEM, time.Now())
+		if err != nil {
+			return err
+		}
+		ks[*alias] = entry
+	}
+
+	return writeKeyStore(*path, ks, pass)
+}
+
+func runExport(args []string) error {
+	fs, path, storepass := newFlagSet("export")
+	alias := fs.String("alias", "", "alias of the entry to export")
+	out := fs.String("out", "-", "output PEM file, - for stdout")
+	fs.Parse(args)
+
+	if *alias == "" {
+		return fmt.Errorf("-alias is required")
+	}
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+	ks, err := readKeyStore(*path, pass)
+	if err != nil {
+		return err
+	}
+	entry, ok := ks[*alias]
+	if !ok {
+		return fmt.Errorf("alias %q not found", *alias)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return keystore.EncodePEM(w, entry)
+}
+
+func runPasswd(args []string) error {
+	fs, path, storepass := newFlagSet("passwd")
+	newpass := fs.String("newpass", "", "new keystore password (default $JKS_NEW_PASSWORD)")
+	fs.Parse(args)
+
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+	next, err := password(*newpass, "JKS_NEW_PASSWORD")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(next)) == "" {
+		return fmt.Errorf("new password is blank")
+	}
+	ks, err := readKeyStore(*path, pass)
+	if err != nil {
+		return err
+	}
+	return writeKeyStore(*path, ks, next)
+}
+
+func runDelete(args []string) error {
+	fs, path, storepass := newFlagSet("delete")
+	alias := fs.String("alias", "", "alias of the entry to delete")
+	fs.Parse(args)
+
+	if *alias == "" {
+		return fmt.Errorf("-alias is required")
+	}
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+	ks, err := readKeyStore(*path, pass)
+	if err != nil {
+		return err
+	}
+	if _, ok := ks[*alias]; !ok {
+		return fmt.Errorf("alias %q not found", *alias)
+	}
+	delete(ks, *alias)
+	return writeKeyStore(*path, ks, pass)
+}
+
+func runBootstrap(args []string) error {
+	fs, path, storepass := newFlagSet("bootstrap")
+	certFile := fs.String("cert", "job_certificate.crt", "output PEM certificate to upload to the Connected App")
+	cn := fs.String("cn", "", "certificate common name")
+	org := fs.String("o", "", "certificate organization")
+	days := fs.Int("days", 365, "certificate validity in days")
+	keySize := fs.Int("keysize", 2048, "RSA key size in bits")
+	force := fs.Bool("force", false, "overwrite existing files")
+	fs.Parse(args)
+
+	if *cn == "" {
+		return fmt.Errorf("-cn is required")
+	}
+	if *days <= 0 {
+		return fmt.Errorf("-days must be positive")
+	}
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+	if !*force {
+		for _, name := range []string{*path, *certFile} {
+			if _, err := os.Stat(name); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite it", name)
+			}
+		}
+	}
+
+	var jks, cert bytes.Buffer
+	info, err := gforce.GenerateJobKeyStore(&jks, &cert, pass, keystore.CertificateOptions{
+		CommonName:   *cn,
+		Organization: *org,
+		KeySize:      *keySize,
+		Validity:     time.Duration(*days) * 24 * time.Hour,
+	})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*certFile, cert.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*path, jks.Bytes(), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s (alias %s) and %s\n", *path, info.Alias, *certFile)
+	fmt.Printf("subject %s, expires %s\n", info.Subject, info.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+func runCheck(args []string) error {
+	fs, path, storepass := newFlagSet("check")
+	days := fs.Int("days", 30, "warn when the certificate expires within this many days")
+	fs.Parse(args)
+
+	pass, err := password(*storepass, "JKS_PASSWORD")
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	info, expiring, err := gforce.CheckJobCertificate(content, pass, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	left := time.Until(info.NotAfter).Round(time.Hour)
+	if expiring {
+		return fmt.Errorf("certificate %s expires %s (%v left)", info.Subject, info.NotAfter.Format(time.RFC3339), left)
+	}
+	fmt.Printf("certificate %s expires %s (%v left)\n", info.Subject, info.NotAfter.Format(time.RFC3339), left)
+	return nil
+}
@@ -0,0 +1,224 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	maxCollectionRecords  = 200
+	maxCollectionRetrieve = 2000
+)
+
+// CollectionOptions controls the sObject Collections calls
+type CollectionOptions struct {
+	// AllOrNone rolls back a chunk of 200 records when one of them fails, other chunks are not affected
+	AllOrNone bool
+	// Concurrency is the number of chunks sent at once, 1 by default
+	Concurrency int
+}
+
+// CollectionResult is the outcome of one record, at the index of the record in the input
+type CollectionResult struct {
+	Id      string
+	Success bool
+	Created bool
+	Errors  []ForceError
+}
+
+type collectionRequest struct {
+	AllOrNone bool          `json:"allOrNone"`
+	Records   []ForceRecord `json:"records"`
+}
+
+// collectionResult is the wire format, errors carry the code in statusCode
+type collectionResult struct {
+	Id      string `json:"id"`
+	Success bool   `json:"success"`
+	Created bool   `json:"created"`
+	Errors  []struct {
+		StatusCode string   `json:"statusCode"`
+		Message    string   `json:"message"`
+		Fields     []string `json:"fields"`
+	} `json:"errors"`
+}
+
+// Err returns a *SubrequestError when the record failed
+func (r CollectionResult) Err() error {
+	if r.Success {
+		return nil
+	}
+	return &SubrequestError{ReferenceId: r.Id, Errors: r.Errors}
+}
+
+func (r *CollectionResult) UnmarshalJSON(data []byte) error {
+	var raw collectionResult
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = CollectionResult{Id: raw.Id, Success: raw.Success, Created: raw.Created}
+	for _, e := range raw.Errors {
+		r.Errors = append(r.Errors, ForceError{Message: e.Message, ErrorCode: e.StatusCode, Fields: e.Fields})
+	}
+	return nil
+}
+
+// CreateRecords creates records of sobject in chunks of 200. results has one entry per record,
+// entries of a chunk whose request failed are left empty and the request error is returned.
+func (f *Force) CreateRecords(ctx context.Context, sobject string, records []ForceRecord, options CollectionOptions) (results []CollectionResult, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/composite/sobjects", f.Credentials.InstanceUrl, apiVersion)
+	return f.sendCollection(ctx, sobject, records, options, func(data string) ([]byte, error) {
+		return f.httpPostJSONContext(ctx, url, data, false)
+	})
+}
+
+// UpdateRecords updates records of sobject in chunks of 200, every record must hold its Id
+func (f *Force) UpdateRecords(ctx context.Context, sobject string, records []ForceRecord, options CollectionOptions) (results []CollectionResult, err error) {
+	for i, record := range records {
+		if id, _ := record["Id"].(string); id == "" {
+			return nil, fmt.Errorf("record %d of %s has no Id", i, sobject)
+		}
+	}
+	url := fmt.Sprintf("%s/services/data/%s/composite/sobjects", f.Credentials.InstanceUrl, apiVersion)
+	return f.sendCollection(ctx, sobject, records, options, func(data string) ([]byte, error) {
+		return f.httpPatchJSONContext(ctx, url, data, false)
+	})
+}
+
+// UpsertRecords creates or updates records of sobject matched on externalIdField, in chunks of 200
+func (f *Force) UpsertRecords(ctx context.Context, sobject, externalIdField string, records []ForceRecord, options CollectionOptions) (results []CollectionResult, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/composite/sobjects/%s/%s", f.Credentials.InstanceUrl, apiVersion, sobject, externalIdField)
+	return f.sendCollection(ctx, sobject, records, options, func(data string) ([]byte, error) {
+		return f.httpPatchJSONContext(ctx, url, data, false)
+	})
+}
+
+// DeleteRecords deletes records by Id in chunks of 200
+func (f *Force) DeleteRecords(ctx context.Context, ids []string, options CollectionOptions) (results []CollectionResult, err error) {
+	results = make([]CollectionResult, len(ids))
+	err = runChunks(ctx, len(ids), maxCollectionRecords, options.Concurrency, func(start, end int) error {
+		url := fmt.Sprintf("%s/services/data/%s/composite/sobjects?ids=%s&allOrNone=%t", f.Credentials.InstanceUrl, apiVersion,
+			url.QueryEscape(strings.Join(ids[start:end], ",")), options.AllOrNone)
+		body, err := f.httpDeleteContext(ctx, url, false)
+		if err != nil {
+			return err
+		}
+		return decodeCollectionResults(body, results[start:end])
+	})
+	return
+}
+
+// RetrieveRecords reads fields of records of sobject by Id in chunks of 2000.
+// records is aligned with ids, with nil for the Ids not found.
+func (f *Force) RetrieveRecords(ctx context.Context, sobject string, ids, fields []string, options CollectionOptions) (records []ForceRecord, err error) {
+	records = make([]ForceRecord, len(ids))
+	url := fmt.Sprintf("%s/services/data/%s/composite/sobjects/%s", f.Credentials.InstanceUrl, apiVersion, sobject)
+	err = runChunks(ctx, len(ids), maxCollectionRetrieve, options.Concurrency, func(start, end int) error {
+		data, err := json.Marshal(map[string][]string{"ids": ids[start:end], "fields": fields})
+		if err != nil {
+			return err
+		}
+		body, err := f.httpPostJSONContext(ctx, url, string(data), false)
+		if err != nil {
+			return err
+		}
+		var chunk []ForceRecord
+		if err = json.Unmarshal(body, &chunk); err != nil {
+			return fmt.Errorf("Error decoding retrieved records: %w", err)
+		}
+		copy(records[start:end], chunk)
+		return nil
+	})
+	return
+}
+
+func (f *Force) sendCollection(ctx context.Context, sobject string, records []ForceRecord, options CollectionOptions, send func(data string) ([]byte, error)) (results []CollectionResult, err error) {
+	typed := make([]ForceRecord, len(records))
+	for i, record := range records {
+		typed[i] = withAttributesType(record, sobject)
+	}
+
+	results = make([]CollectionResult, len(records))
+	err = runChunks(ctx, len(records), maxCollectionRecords, options.Concurrency, func(start, end int) error {
+		data, err := json.Marshal(collectionRequest{AllOrNone: options.AllOrNone, Records: typed[start:end]})
+		if err != nil {
+			return err
+		}
+		body, err := send(string(data))
+		if err != nil {
+			return err
+		}
+		return decodeCollectionResults(body, results[start:end])
+	})
+	return
+}
+
+// withAttributesType returns a copy of record with attributes.type set, as collections require
+func withAttributesType(record ForceRecord, sobject string) ForceRecord {
+	if attributes, ok := record["attributes"].(map[string]interface{}); ok && attributes["type"] != nil {
+		return record
+	}
+	typed := make(ForceRecord, len(record)+1)
+	for k, v := range record {
+		typed[k] = v
+	}
+	typed["attributes"] = map[string]interface{}{"type": sobject}
+	return typed
+}
+
+func decodeCollectionResults(body []byte, results []CollectionResult) error {
+	var chunk []CollectionResult
+	if err := json.Unmarshal(body, &chunk); err != nil {
+		return fmt.Errorf("Error decoding collection results: %w", err)
+	}
+	if len(chunk) != len(results) {
+		return fmt.Errorf("Expected %d collection results, got %d", len(results), len(chunk))
+	}
+	copy(results, chunk)
+	return nil
+}
+
+// runChunks calls send for each [start, end) chunk of n items, with up to concurrency calls at
+// once, and combines their errors. Chunks not started when ctx is done report ctx.Err().
+func runChunks(ctx context.Context, n, size, concurrency int, send func(start, end int) error) (err error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			err = multierr.Append(err, fmt.Errorf("chunk %d-%d: %w", start, end, ctx.Err()))
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if e := send(start, end); e != nil {
+				mu.Lock()
+				err = multierr.Append(err, fmt.Errorf("chunk %d-%d: %w", start, end, e))
+				mu.Unlock()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return
+}
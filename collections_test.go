@@ -0,0 +1,115 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCreateRecordsChunks(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sizes = map[string]int{}
+	)
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			AllOrNone bool                     `json:"allOrNone"`
+			Records   []map[string]interface{} `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("request decoding error '%v'", err)
+		}
+		first := request.Records[0]["Name"].(string)
+		mu.Lock()
+		sizes[first] = len(request.Records)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if first == "R200" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `[{"errorCode":"JSON_PARSER_ERROR","message":"Unexpected character"}]`)
+			return
+		}
+		results := make([]string, len(request.Records))
+		for i, record := range request.Records {
+			if attributes, _ := record["attributes"].(map[string]interface{}); attributes["type"] != "Account" {
+				t.Errorf("record attributes '%v', expected type Account", record["attributes"])
+			}
+			if record["Name"] == "R5" {
+				results[i] = `{"success":false,"errors":[{"statusCode":"REQUIRED_FIELD_MISSING","message":"Required fields are missing: [Site]","fields":["Site"]}]}`
+				continue
+			}
+			results[i] = fmt.Sprintf(`{"id":"ID%s","success":true,"errors":[]}`, record["Name"])
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(results, ","))
+	})
+
+	records := make([]ForceRecord, 450)
+	for i := range records {
+		records[i] = ForceRecord{"Name": fmt.Sprintf("R%d", i)}
+	}
+	results, err := f.CreateRecords(context.Background(), "Account", records, CollectionOptions{Concurrency: 2})
+
+	if expected := map[string]int{"R0": 200, "R200": 200, "R400": 50}; !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("chunks '%v', expected '%v'", sizes, expected)
+	}
+	if err == nil || !strings.Contains(err.Error(), "chunk 200-400") {
+		t.Errorf("error '%v', expected the failure of chunk 200-400", err)
+	}
+	if len(results) != len(records) {
+		t.Fatalf("%d results, expected %d", len(results), len(records))
+	}
+
+	type resultItem struct {
+		index   int
+		id      string
+		success bool
+		code    string
+	}
+	table := []resultItem{
+		{index: 0, id: "IDR0", success: true},
+		{index: 5, success: false, code: "REQUIRED_FIELD_MISSING"},
+		{index: 199, id: "IDR199", success: true},
+		{index: 200},
+		{index: 399},
+		{index: 449, id: "IDR449", success: true},
+	}
+	for _, tt := range table {
+		result := results[tt.index]
+		if result.Id != tt.id || result.Success != tt.success {
+			t.Errorf("result %d '%+v', expected id '%v' success %v", tt.index, result, tt.id, tt.success)
+		}
+		if tt.code == "" {
+			continue
+		}
+		var subrequestErr *SubrequestError
+		if !errors.As(result.Err(), &subrequestErr) || subrequestErr.Errors[0].ErrorCode != tt.code {
+			t.Errorf("result %d error '%v', expected '%v'", tt.index, result.Err(), tt.code)
+		}
+	}
+}
+
+func TestDeleteRecordsContext(t *testing.T) {
+	var requests int32
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `[{"id":"001000000000001AAA","success":true,"errors":[]}]`)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.DeleteRecords(ctx, []string{"001000000000001AAA"}, CollectionOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error '%v', expected '%v'", err, context.Canceled)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("%d requests sent with a cancelled context", n)
+	}
+}
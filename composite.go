@@ -0,0 +1,333 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	maxCompositeSubrequests = 25
+	maxCompositeQueries     = 5
+)
+
+var (
+	CompositeLimitError            = errors.New("Too many composite subrequests")
+	DuplicateReferenceIdError      = errors.New("Duplicate composite reference id")
+	CompositeProcessingHaltedError = errors.New("Processing halted")
+	DuplicateValueError            = errors.New("Duplicate value")
+)
+
+// CompositeSubrequest is a REST call inside a composite request, URL is relative to the instance
+type CompositeSubrequest struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	ReferenceId string            `json:"referenceId"`
+	Body        interface{}       `json:"body,omitempty"`
+	HttpHeaders map[string]string `json:"httpHeaders,omitempty"`
+}
+
+// CompositeSubresponse is the outcome of a subrequest, Body is left raw for Decode
+type CompositeSubresponse struct {
+	Body           json.RawMessage   `json:"body"`
+	HttpHeaders    map[string]string `json:"httpHeaders"`
+	HttpStatusCode int               `json:"httpStatusCode"`
+	ReferenceId    string            `json:"referenceId"`
+}
+
+// CompositeResult lists the subresponses in request order
+type CompositeResult struct {
+	CompositeResponse []CompositeSubresponse `json:"compositeResponse"`
+}
+
+// SubrequestError is a failed subrequest or REST call. It unwraps to the library error matching
+// the first error code (EntityIsDeleted, SessionExpiredError...) so errors.Is works across APIs.
+type SubrequestError struct {
+	ReferenceId    string
+	HttpStatusCode int
+	Errors         []ForceError
+}
+
+// Composite builds a /composite request. Later subrequests use the results of earlier ones
+// through references:
+//
+//	c := NewComposite().AllOrNone(true)
+//	c.Create("refAccount", "Account", ForceRecord{"Name": "Acme"})
+//	c.Create("refContact", "Contact", ForceRecord{"LastName": "Smith", "AccountId": Ref("refAccount", "id")})
+//	result, err := f.Composite(c)
+type Composite struct {
+	allOrNone   bool
+	collate     bool
+	subrequests []CompositeSubrequest
+}
+
+type compositeBody struct {
+	AllOrNone          bool                  `json:"allOrNone"`
+	CollateSubrequests bool                  `json:"collateSubrequests"`
+	CompositeRequest   []CompositeSubrequest `json:"compositeRequest"`
+}
+
+func NewComposite() *Composite {
+	return &Composite{}
+}
+
+// Ref returns the reference to a field of an earlier subresponse, e.g. Ref("refAccount", "id")
+func Ref(referenceId, field string) string {
+	return fmt.Sprintf("@{%s.%s}", referenceId, field)
+}
+
+// AllOrNone rolls back every subrequest when one fails
+func (c *Composite) AllOrNone(allOrNone bool) *Composite {
+	c.allOrNone = allOrNone
+	return c
+}
+
+// CollateSubrequests lets Salesforce run independent subrequests in parallel
+func (c *Composite) CollateSubrequests(collate bool) *Composite {
+	c.collate = collate
+	return c
+}
+
+func (c *Composite) Add(subrequest CompositeSubrequest) *Composite {
+	c.subrequests = append(c.subrequests, subrequest)
+	return c
+}
+
+func (c *Composite) Create(referenceId, sobject string, record ForceRecord) *Composite {
+	return c.Add(CompositeSubrequest{
+		Method:      "POST",
+		URL:         fmt.Sprintf("/services/data/%s/sobjects/%s", apiVersion, sobject),
+		ReferenceId: referenceId,
+		Body:        record,
+	})
+}
+
+func (c *Composite) Update(referenceId, sobject, id string, record ForceRecord) *Composite {
+	return c.Add(CompositeSubrequest{
+		Method:      "PATCH",
+		URL:         fmt.Sprintf("/services/data/%s/sobjects/%s/%s", apiVersion, sobject, id),
+		ReferenceId: referenceId,
+		Body:        record,
+	})
+}
+
+func (c *Composite) Upsert(referenceId, sobject, externalIdField, externalId string, record ForceRecord) *Composite {
+	return c.Add(CompositeSubrequest{
+		Method:      "PATCH",
+		URL:         fmt.Sprintf("/services/data/%s/sobjects/%s/%s/%s", apiVersion, sobject, externalIdField, url.PathEscape(externalId)),
+		ReferenceId: referenceId,
+		Body:        record,
+	})
+}
+
+func (c *Composite) Delete(referenceId, sobject, id string) *Composite {
+	return c.Add(CompositeSubrequest{
+		Method:      "DELETE",
+		URL:         fmt.Sprintf("/services/data/%s/sobjects/%s/%s", apiVersion, sobject, id),
+		ReferenceId: referenceId,
+	})
+}
+
+func (c *Composite) Get(referenceId, sobject, id string, fields ...string) *Composite {
+	path := fmt.Sprintf("/services/data/%s/sobjects/%s/%s", apiVersion, sobject, id)
+	if len(fields) > 0 {
+		path += "?fields=" + strings.Join(fields, ",")
+	}
+	return c.Add(CompositeSubrequest{Method: "GET", URL: path, ReferenceId: referenceId})
+}
+
+// Query adds a SOQL query, its records are referenced as Ref(referenceId, "records[0].Id").
+// References inside the query are kept unescaped so that they are resolved.
+func (c *Composite) Query(referenceId, query string) *Composite {
+	path := queryOptionsPath("", QueryOptions{}) + escapeCompositeQuery(query)
+	return c.Add(CompositeSubrequest{Method: "GET", URL: path, ReferenceId: referenceId})
+}
+
+// escapeCompositeQuery query-escapes the SOQL between @{...} references, which are kept as is
+// so that the server resolves them, brackets and nested braces included
+func escapeCompositeQuery(query string) string {
+	var escaped strings.Builder
+	for {
+		start := strings.Index(query, "@{")
+		if start < 0 {
+			break
+		}
+		end, depth := -1, 0
+		for i := start + 1; i < len(query) && end < 0; i++ {
+			switch query[i] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = i + 1
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		escaped.WriteString(url.QueryEscape(query[:start]))
+		escaped.WriteString(query[start:end])
+		query = query[end:]
+	}
+	escaped.WriteString(url.QueryEscape(query))
+	return escaped.String()
+}
+
+func (c *Composite) validate() error {
+	return c.validateNodes(maxCompositeSubrequests, maxCompositeQueries)
+}
+
+// validateNodes checks the subrequest count, the query count when maxQueries is set and the reference ids
+func (c *Composite) validateNodes(max, maxQueries int) error {
+	if len(c.subrequests) > max {
+		return fmt.Errorf("%w: %d, the maximum is %d", CompositeLimitError, len(c.subrequests), max)
+	}
+	seen := map[string]bool{}
+	queries := 0
+	for _, subrequest := range c.subrequests {
+		if seen[subrequest.ReferenceId] {
+			return fmt.Errorf("%w: %s", DuplicateReferenceIdError, subrequest.ReferenceId)
+		}
+		seen[subrequest.ReferenceId] = true
+		if strings.Contains(subrequest.URL, "/query") {
+			queries++
+		}
+	}
+	if maxQueries > 0 && queries > maxQueries {
+		return fmt.Errorf("%w: %d queries, the maximum is %d", CompositeLimitError, queries, maxQueries)
+	}
+	return nil
+}
+
+// Composite sends c in one round trip. A failed subrequest is not an error of the call,
+// check result.Err() or the Err of each subresponse.
+func (f *Force) Composite(c *Composite) (result CompositeResult, err error) {
+	if err = c.validate(); err != nil {
+		return
+	}
+	data, err := json.Marshal(compositeBody{
+		AllOrNone:          c.allOrNone,
+		CollateSubrequests: c.collate,
+		CompositeRequest:   c.subrequests,
+	})
+	if err != nil {
+		return
+	}
+	url := fmt.Sprintf("%s/services/data/%s/composite", f.Credentials.InstanceUrl, apiVersion)
+	res, body, err := f.httpSendJSON("POST", url, string(data), nil, false)
+	if err != nil {
+		return
+	}
+	if res.StatusCode/100 != 2 {
+		return result, newSubrequestError("", res.StatusCode, body)
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding composite response: %w", err)
+	}
+	return
+}
+
+// Get returns the subresponse of referenceId
+func (r CompositeResult) Get(referenceId string) (CompositeSubresponse, bool) {
+	for _, subresponse := range r.CompositeResponse {
+		if subresponse.ReferenceId == referenceId {
+			return subresponse, true
+		}
+	}
+	return CompositeSubresponse{}, false
+}
+
+// Err combines the errors of every failed subrequest
+func (r CompositeResult) Err() (err error) {
+	for _, subresponse := range r.CompositeResponse {
+		err = multierr.Append(err, subresponse.Err())
+	}
+	return
+}
+
+func (r CompositeSubresponse) Success() bool {
+	return r.HttpStatusCode/100 == 2
+}
+
+// Err returns a *SubrequestError when the subrequest failed
+func (r CompositeSubresponse) Err() error {
+	if r.Success() {
+		return nil
+	}
+	return newSubrequestError(r.ReferenceId, r.HttpStatusCode, r.Body)
+}
+
+// Id returns the id of a created record
+func (r CompositeSubresponse) Id() string {
+	var created ForceCreateRecordResult
+	json.Unmarshal(r.Body, &created)
+	return created.Id
+}
+
+// Decode unmarshals the body of a successful subresponse into out
+func (r CompositeSubresponse) Decode(out interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if len(r.Body) == 0 || string(r.Body) == "null" {
+		return nil
+	}
+	return json.Unmarshal(r.Body, out)
+}
+
+func newSubrequestError(referenceId string, status int, body json.RawMessage) *SubrequestError {
+	e := &SubrequestError{ReferenceId: referenceId, HttpStatusCode: status}
+	if json.Unmarshal(body, &e.Errors) != nil {
+		var single ForceError
+		if json.Unmarshal(body, &single) == nil && single.ErrorCode != "" {
+			e.Errors = []ForceError{single}
+		} else {
+			e.Errors = []ForceError{{Message: string(body)}}
+		}
+	}
+	return e
+}
+
+func (e *SubrequestError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		messages[i] = e.Errors[i].Error()
+	}
+	message := strings.Join(messages, "; ")
+	if e.HttpStatusCode != 0 {
+		message = fmt.Sprintf("%d %s", e.HttpStatusCode, message)
+	}
+	if e.ReferenceId == "" {
+		return message
+	}
+	return fmt.Sprintf("%s: %s", e.ReferenceId, message)
+}
+
+func (e *SubrequestError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return mapForceError(e.Errors[0])
+}
+
+// mapForceError returns the library error for an error code, or the ForceError itself
+func mapForceError(e ForceError) error {
+	switch e.ErrorCode {
+	case "ENTITY_IS_DELETED":
+		return EntityIsDeleted
+	case "NOT_FOUND":
+		return DeleteRecordResourceNotExistsError
+	case "INVALID_SESSION_ID":
+		return SessionExpiredError
+	case "PROCESSING_HALTED", "ALL_OR_NONE_OPERATION_ROLLED_BACK", "BATCH_PROCESSING_HALTED":
+		return CompositeProcessingHaltedError
+	case "DUPLICATE_VALUE":
+		return DuplicateValueError
+	}
+	return &e
+}
@@ -0,0 +1,241 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	maxBatchSubrequests = 25
+	maxGraphNodes       = 500
+	minGraphApiVersion  = 50.0
+)
+
+var ApiVersionUnsupportedError = errors.New("Unsupported API version")
+
+// BatchSubrequest is an independent call of a /composite/batch request, URL is relative to
+// /services/data (e.g. v46.0/sobjects/Account/001...)
+type BatchSubrequest struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	RichInput interface{} `json:"richInput,omitempty"`
+}
+
+// BatchSubresponse is the outcome of a batch subrequest, in request order
+type BatchSubresponse struct {
+	StatusCode int             `json:"statusCode"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type CompositeBatchResult struct {
+	HasErrors bool               `json:"hasErrors"`
+	Results   []BatchSubresponse `json:"results"`
+}
+
+// CompositeBatch builds a /composite/batch request of up to 25 subrequests that do not depend
+// on each other. They are not rolled back when one fails.
+type CompositeBatch struct {
+	haltOnError bool
+	requests    []BatchSubrequest
+}
+
+type compositeBatchBody struct {
+	HaltOnError   bool              `json:"haltOnError"`
+	BatchRequests []BatchSubrequest `json:"batchRequests"`
+}
+
+// CompositeGraph builds a /composite/graph request. Each graph is a Composite run atomically,
+// with up to 500 nodes; graphs succeed or fail independently. AllOrNone and
+// CollateSubrequests of the graphs are ignored. The endpoint exists from API v50.0, see
+// SetApiVersion.
+//
+//	g := NewCompositeGraph().Add("graph1", NewComposite().Create("refAccount", "Account", account))
+type CompositeGraph struct {
+	graphs []compositeGraph
+}
+
+type compositeGraph struct {
+	GraphId          string                `json:"graphId"`
+	CompositeRequest []CompositeSubrequest `json:"compositeRequest"`
+}
+
+// GraphResponse is the outcome of a graph, GraphResponse holds the subresponses of its nodes
+type GraphResponse struct {
+	GraphId       string          `json:"graphId"`
+	GraphResponse CompositeResult `json:"graphResponse"`
+	IsSuccessful  bool            `json:"isSuccessful"`
+}
+
+type CompositeGraphResult struct {
+	Graphs []GraphResponse `json:"graphs"`
+}
+
+func NewCompositeBatch() *CompositeBatch {
+	return &CompositeBatch{}
+}
+
+// HaltOnError skips the remaining subrequests after one fails, they report BATCH_PROCESSING_HALTED
+func (b *CompositeBatch) HaltOnError(halt bool) *CompositeBatch {
+	b.haltOnError = halt
+	return b
+}
+
+func (b *CompositeBatch) Add(request BatchSubrequest) *CompositeBatch {
+	b.requests = append(b.requests, request)
+	return b
+}
+
+func (b *CompositeBatch) Create(sobject string, record ForceRecord) *CompositeBatch {
+	return b.Add(BatchSubrequest{Method: "POST", URL: fmt.Sprintf("%s/sobjects/%s", apiVersion, sobject), RichInput: record})
+}
+
+func (b *CompositeBatch) Update(sobject, id string, record ForceRecord) *CompositeBatch {
+	return b.Add(BatchSubrequest{Method: "PATCH", URL: fmt.Sprintf("%s/sobjects/%s/%s", apiVersion, sobject, id), RichInput: record})
+}
+
+func (b *CompositeBatch) Upsert(sobject, externalIdField, externalId string, record ForceRecord) *CompositeBatch {
+	return b.Add(BatchSubrequest{
+		Method:    "PATCH",
+		URL:       fmt.Sprintf("%s/sobjects/%s/%s/%s", apiVersion, sobject, externalIdField, url.PathEscape(externalId)),
+		RichInput: record,
+	})
+}
+
+func (b *CompositeBatch) Delete(sobject, id string) *CompositeBatch {
+	return b.Add(BatchSubrequest{Method: "DELETE", URL: fmt.Sprintf("%s/sobjects/%s/%s", apiVersion, sobject, id)})
+}
+
+func (b *CompositeBatch) Get(sobject, id string, fields ...string) *CompositeBatch {
+	path := fmt.Sprintf("%s/sobjects/%s/%s", apiVersion, sobject, id)
+	if len(fields) > 0 {
+		path += "?fields=" + strings.Join(fields, ",")
+	}
+	return b.Add(BatchSubrequest{Method: "GET", URL: path})
+}
+
+func (b *CompositeBatch) Query(query string) *CompositeBatch {
+	path := strings.TrimPrefix(queryOptionsPath(query, QueryOptions{}), "/services/data/")
+	return b.Add(BatchSubrequest{Method: "GET", URL: path})
+}
+
+// CompositeBatch sends b in one round trip, failed subrequests are reported by result.Err()
+func (f *Force) CompositeBatch(b *CompositeBatch) (result CompositeBatchResult, err error) {
+	if len(b.requests) > maxBatchSubrequests {
+		err = fmt.Errorf("%w: %d, the maximum is %d", CompositeLimitError, len(b.requests), maxBatchSubrequests)
+		return
+	}
+	data, err := json.Marshal(compositeBatchBody{HaltOnError: b.haltOnError, BatchRequests: b.requests})
+	if err != nil {
+		return
+	}
+	url := fmt.Sprintf("%s/services/data/%s/composite/batch", f.Credentials.InstanceUrl, apiVersion)
+	body, err := f.httpPostJSON(url, string(data), false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding composite batch response: %w", err)
+	}
+	return
+}
+
+// Err combines the errors of every failed subrequest
+func (r CompositeBatchResult) Err() (err error) {
+	for _, subresponse := range r.Results {
+		err = multierr.Append(err, subresponse.Err())
+	}
+	return
+}
+
+func (r BatchSubresponse) subresponse() CompositeSubresponse {
+	return CompositeSubresponse{Body: r.Result, HttpStatusCode: r.StatusCode}
+}
+
+func (r BatchSubresponse) Success() bool {
+	return r.subresponse().Success()
+}
+
+// Err returns a *SubrequestError when the subrequest failed
+func (r BatchSubresponse) Err() error {
+	return r.subresponse().Err()
+}
+
+// Id returns the id of a created record
+func (r BatchSubresponse) Id() string {
+	return r.subresponse().Id()
+}
+
+// Decode unmarshals the result of a successful subrequest into out
+func (r BatchSubresponse) Decode(out interface{}) error {
+	return r.subresponse().Decode(out)
+}
+
+func NewCompositeGraph() *CompositeGraph {
+	return &CompositeGraph{}
+}
+
+func (g *CompositeGraph) Add(graphId string, c *Composite) *CompositeGraph {
+	g.graphs = append(g.graphs, compositeGraph{GraphId: graphId, CompositeRequest: c.subrequests})
+	return g
+}
+
+// CompositeGraph sends the graphs of g in one round trip. It needs API v50.0 or later and
+// returns ApiVersionUnsupportedError otherwise.
+func (f *Force) CompositeGraph(g *CompositeGraph) (result CompositeGraphResult, err error) {
+	if version, e := strconv.ParseFloat(apiVersionNumber, 64); e != nil || version < minGraphApiVersion {
+		return result, fmt.Errorf("%w: composite graph needs v%.1f or later, the API version is %s", ApiVersionUnsupportedError, minGraphApiVersion, apiVersion)
+	}
+	seen := map[string]bool{}
+	for _, graph := range g.graphs {
+		if seen[graph.GraphId] {
+			return result, fmt.Errorf("Duplicate graph id %s", graph.GraphId)
+		}
+		seen[graph.GraphId] = true
+		if err = (&Composite{subrequests: graph.CompositeRequest}).validateNodes(maxGraphNodes, 0); err != nil {
+			return result, fmt.Errorf("graph %s: %w", graph.GraphId, err)
+		}
+	}
+	data, err := json.Marshal(map[string][]compositeGraph{"graphs": g.graphs})
+	if err != nil {
+		return
+	}
+	url := fmt.Sprintf("%s/services/data/%s/composite/graph", f.Credentials.InstanceUrl, apiVersion)
+	body, err := f.httpPostJSON(url, string(data), false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding composite graph response: %w", err)
+	}
+	return
+}
+
+// Get returns the response of graphId
+func (r CompositeGraphResult) Get(graphId string) (GraphResponse, bool) {
+	for _, graph := range r.Graphs {
+		if graph.GraphId == graphId {
+			return graph, true
+		}
+	}
+	return GraphResponse{}, false
+}
+
+// Err combines the errors of the failed graphs
+func (r CompositeGraphResult) Err() (err error) {
+	for _, graph := range r.Graphs {
+		if !graph.IsSuccessful {
+			if e := graph.GraphResponse.Err(); e != nil {
+				err = multierr.Append(err, fmt.Errorf("graph %s: %w", graph.GraphId, e))
+			} else {
+				err = multierr.Append(err, fmt.Errorf("graph %s: %w", graph.GraphId, CompositeProcessingHaltedError))
+			}
+		}
+	}
+	return
+}
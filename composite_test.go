@@ -0,0 +1,113 @@
+package gforce
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCompositeQueryReferences(t *testing.T) {
+	type queryItem struct {
+		query string
+		path  string
+	}
+	prefix := "/services/data/" + apiVersion + "/query?q="
+	table := []queryItem{
+		{
+			query: "SELECT Id FROM Account",
+			path:  prefix + "SELECT+Id+FROM+Account",
+		},
+		{
+			query: "SELECT Id FROM Contact WHERE AccountId = '@{refAccount.id}'",
+			path:  prefix + "SELECT+Id+FROM+Contact+WHERE+AccountId+%3D+%27@{refAccount.id}%27",
+		},
+		{
+			query: "SELECT Id FROM Case WHERE ContactId = '@{refQ.records[0].Id}'",
+			path:  prefix + "SELECT+Id+FROM+Case+WHERE+ContactId+%3D+%27@{refQ.records[0].Id}%27",
+		},
+		{
+			query: "SELECT Id FROM User WHERE Id = '@{refQ.records[0].Owner.Id}' OR Id = '@{refU.records[1].Id}'",
+			path:  prefix + "SELECT+Id+FROM+User+WHERE+Id+%3D+%27@{refQ.records[0].Owner.Id}%27+OR+Id+%3D+%27@{refU.records[1].Id}%27",
+		},
+		{
+			query: "SELECT Id FROM Account WHERE Name = '@{ref{nested}.Name}'",
+			path:  prefix + "SELECT+Id+FROM+Account+WHERE+Name+%3D+%27@{ref{nested}.Name}%27",
+		},
+		{
+			query: "SELECT Id FROM Account WHERE Name = '@{unterminated'",
+			path:  prefix + "SELECT+Id+FROM+Account+WHERE+Name+%3D+%27%40%7Bunterminated%27",
+		},
+	}
+	for _, tt := range table {
+		composite := NewComposite().Query("ref", tt.query)
+		if path := composite.subrequests[0].URL; path != tt.path {
+			t.Errorf("invalid path for '%v': '%v', expected '%v'", tt.query, path, tt.path)
+		}
+	}
+}
+
+func TestCompositeGraphApiVersion(t *testing.T) {
+	defer SetApiVersion(apiVersionNumber)
+	SetApiVersion("46.0")
+	_, err := (&Force{}).CompositeGraph(NewCompositeGraph())
+	if !errors.Is(err, ApiVersionUnsupportedError) {
+		t.Errorf("expected unsupported version error '%v'", err)
+	}
+}
+
+func TestCompositeResponse(t *testing.T) {
+	type responseItem struct {
+		status      int
+		body        string
+		created     string
+		subrequests bool
+		err         string
+	}
+	table := []responseItem{
+		{
+			status:      http.StatusOK,
+			body:        `{"compositeResponse":[{"body":{"id":"001000000000001AAA","success":true,"errors":[]},"httpHeaders":{},"httpStatusCode":201,"referenceId":"refAccount"},{"body":[{"errorCode":"PROCESSING_HALTED","message":"The transaction was rolled back"}],"httpHeaders":{},"httpStatusCode":400,"referenceId":"refContact"}]}`,
+			created:     "001000000000001AAA",
+			subrequests: true,
+		},
+		{
+			status: http.StatusBadRequest,
+			body:   `[{"errorCode":"JSON_PARSER_ERROR","message":"Unexpected character"}]`,
+			err:    "JSON_PARSER_ERROR",
+		},
+	}
+	for _, tt := range table {
+		f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+		c := NewComposite().
+			Create("refAccount", "Account", ForceRecord{"Name": "Acme"}).
+			Create("refContact", "Contact", ForceRecord{"LastName": "Doe", "AccountId": "@{refAccount.id}"})
+		result, err := f.Composite(c)
+
+		if tt.err == "" {
+			if err != nil {
+				t.Fatalf("composite error '%v'", err)
+			}
+			account, _ := result.Get("refAccount")
+			if account.Id() != tt.created {
+				t.Errorf("created id '%v', expected '%v'", account.Id(), tt.created)
+			}
+			if !errors.Is(result.Err(), CompositeProcessingHaltedError) {
+				t.Errorf("subrequest error '%v', expected '%v'", result.Err(), CompositeProcessingHaltedError)
+			}
+			continue
+		}
+
+		var subrequestErr *SubrequestError
+		if !errors.As(err, &subrequestErr) {
+			t.Fatalf("composite error '%v', expected a *SubrequestError", err)
+		}
+		if subrequestErr.HttpStatusCode != tt.status || subrequestErr.Errors[0].ErrorCode != tt.err {
+			t.Errorf("composite error '%v', expected %v %v", err, tt.status, tt.err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package gforce
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RecordCondition holds the HTTP preconditions of a record request. IfModifiedSince and
+// IfNoneMatch apply to reads, IfUnmodifiedSince and IfMatch to writes; zero values are not sent.
+type RecordCondition struct {
+	IfModifiedSince   time.Time
+	IfNoneMatch       string
+	IfUnmodifiedSince time.Time
+	IfMatch           string
+}
+
+// ConditionalRecord is the outcome of a conditional read. When NotModified is set, Record is
+// nil and the copy the caller holds is current.
+type ConditionalRecord struct {
+	Record       ForceRecord
+	NotModified  bool
+	ETag         string
+	LastModified time.Time
+}
+
+// RecordConflictError is returned by a conditional write when the record was modified since
+// the caller read it (HTTP 412)
+type RecordConflictError struct {
+	SObject string
+	Id      string
+	Errors  []ForceError
+}
+
+func (e *RecordConflictError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		messages[i] = e.Errors[i].Error()
+	}
+	return fmt.Sprintf("%s %s was modified: %s", e.SObject, e.Id, strings.Join(messages, "; "))
+}
+
+func (c RecordCondition) headers() map[string]string {
+	headers := map[string]string{}
+	if !c.IfModifiedSince.IsZero() {
+		headers["If-Modified-Since"] = c.IfModifiedSince.UTC().Format(http.TimeFormat)
+	}
+	if c.IfNoneMatch != "" {
+		headers["If-None-Match"] = quoteETag(c.IfNoneMatch)
+	}
+	if !c.IfUnmodifiedSince.IsZero() {
+		headers["If-Unmodified-Since"] = c.IfUnmodifiedSince.UTC().Format(http.TimeFormat)
+	}
+	if c.IfMatch != "" {
+		headers["If-Match"] = quoteETag(c.IfMatch)
+	}
+	return headers
+}
+
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// GetRecordConditional reads a record unless it is unchanged since condition.IfModifiedSince or
+// still has the ETag condition.IfNoneMatch. id is either a record Id or externalIdField:value.
+// Pass the returned ETag or LastModified to the next read, or to UpdateRecordConditional.
+func (f *Force) GetRecordConditional(sobject, id string, condition RecordCondition, fields ...string) (result ConditionalRecord, err error) {
+	url := recordURL(f, sobject, id)
+	if len(fields) > 0 {
+		url += "?fields=" + strings.Join(fields, ",")
+	}
+	res, body, err := f.httpSendJSON("GET", url, "", condition.headers(), false)
+	if err != nil {
+		return
+	}
+	result.ETag = res.Header.Get("ETag")
+	if lastModified := res.Header.Get("Last-Modified"); lastModified != "" {
+		result.LastModified, _ = http.ParseTime(lastModified)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		result.NotModified = true
+	case res.StatusCode/100 != 2:
+		err = newSubrequestError("", res.StatusCode, body)
+	default:
+		if err = json.Unmarshal(body, &result.Record); err != nil {
+			err = fmt.Errorf("Error decoding record: %w", err)
+		}
+	}
+	return
+}
+
+// UpdateRecordConditional updates a record from a map or a struct (see EncodeRecord) only when it
+// is unchanged since condition.IfUnmodifiedSince or still has the ETag condition.IfMatch,
+// otherwise it returns a *RecordConflictError and the record is left as is
+func (f *Force) UpdateRecordConditional(sobject, id string, record interface{}, condition RecordCondition) (err error) {
+	values, err := EncodeRecord(record)
+	if err != nil {
+		return
+	}
+	delete(values, "Id")
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	res, body, err := f.httpSendJSON("PATCH", recordURL(f, sobject, id), string(data), condition.headers(), false)
+	if err != nil {
+		return
+	}
+	switch {
+	case res.StatusCode == http.StatusPreconditionFailed:
+		conflict := &RecordConflictError{SObject: sobject, Id: id}
+		conflict.Errors = newSubrequestError("", res.StatusCode, body).Errors
+		err = conflict
+	case res.StatusCode/100 != 2:
+		err = newSubrequestError("", res.StatusCode, body)
+	}
+	return
+}
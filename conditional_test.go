@@ -0,0 +1,87 @@
+package gforce
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetRecordConditional(t *testing.T) {
+	lastModified := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		if r.Header.Get("If-None-Match") == `"v2"` || r.Header.Get("If-Modified-Since") == lastModified.Format(http.TimeFormat) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, `{"Id":"001000000000001AAA","Name":"Acme"}`)
+	})
+
+	type getItem struct {
+		condition   RecordCondition
+		notModified bool
+	}
+	table := []getItem{
+		{condition: RecordCondition{}},
+		{condition: RecordCondition{IfNoneMatch: "v1"}},
+		{condition: RecordCondition{IfNoneMatch: "v2"}, notModified: true},
+		{condition: RecordCondition{IfModifiedSince: lastModified}, notModified: true},
+	}
+	for _, tt := range table {
+		result, err := f.GetRecordConditional("Account", "001000000000001AAA", tt.condition, "Id", "Name")
+		if err != nil {
+			t.Fatalf("%+v: error '%v'", tt.condition, err)
+		}
+		if result.NotModified != tt.notModified {
+			t.Errorf("%+v: not modified %v, expected %v", tt.condition, result.NotModified, tt.notModified)
+		}
+		if tt.notModified && result.Record != nil || !tt.notModified && result.Record["Name"] != "Acme" {
+			t.Errorf("%+v: record '%v'", tt.condition, result.Record)
+		}
+		if result.ETag != `"v2"` || !result.LastModified.Equal(lastModified) {
+			t.Errorf("%+v: etag '%v' last modified '%v'", tt.condition, result.ETag, result.LastModified)
+		}
+	}
+}
+
+func TestUpdateRecordConditional(t *testing.T) {
+	var ifMatch string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		ifMatch = r.Header.Get("If-Match")
+		w.Header().Set("Content-Type", "application/json")
+		switch ifMatch {
+		case `"v2"`:
+			w.WriteHeader(http.StatusNoContent)
+		case `"v1"`:
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, `[{"errorCode":"PRECONDITION_FAILED","message":"The requested resource has been modified"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `[{"errorCode":"NOT_FOUND","message":"The requested resource does not exist"}]`)
+		}
+	})
+
+	if err := f.UpdateRecordConditional("Account", "001000000000001AAA", ForceRecord{"Name": "Acme"}, RecordCondition{IfMatch: "v2"}); err != nil {
+		t.Errorf("update error '%v'", err)
+	}
+	if ifMatch != `"v2"` {
+		t.Errorf("If-Match '%v', expected '%v'", ifMatch, `"v2"`)
+	}
+
+	err := f.UpdateRecordConditional("Account", "001000000000001AAA", ForceRecord{"Name": "Acme"}, RecordCondition{IfMatch: `"v1"`})
+	var conflict *RecordConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("error '%v', expected a *RecordConflictError", err)
+	}
+	if conflict.SObject != "Account" || conflict.Id != "001000000000001AAA" || conflict.Errors[0].ErrorCode != "PRECONDITION_FAILED" {
+		t.Errorf("conflict '%+v'", conflict)
+	}
+
+	err = f.UpdateRecordConditional("Account", "001000000000001AAA", ForceRecord{"Name": "Acme"}, RecordCondition{IfMatch: "v0"})
+	if !errors.Is(err, DeleteRecordResourceNotExistsError) || errors.As(err, &conflict) {
+		t.Errorf("error '%v', expected '%v'", err, DeleteRecordResourceNotExistsError)
+	}
+}
@@ -0,0 +1,452 @@
+package gforce
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Salesforce serializes datetimes as 2006-01-02T15:04:05.000+0000, dates as 2006-01-02 and
+// times as 15:04:05.000Z. RFC 3339 is accepted as well for values written by other tools.
+var salesforceTimeLayouts = []string{
+	"2006-01-02T15:04:05.000-0700",
+	"2006-01-02T15:04:05-0700",
+	time.RFC3339Nano,
+	"2006-01-02",
+	"15:04:05.000Z",
+	"15:04:05Z",
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	dateType    = reflect.TypeOf(Date{})
+	forceRecord = reflect.TypeOf(ForceRecord{})
+	decodePlans sync.Map
+)
+
+type decodeField struct {
+	index    []int
+	path     []string
+	kind     decodeFieldKind
+	children *decodePlan
+}
+
+type decodeFieldKind int
+
+const (
+	decodeValue decodeFieldKind = iota
+	decodeParent
+	decodeChildren
+)
+
+type decodePlan struct {
+	fields []decodeField
+}
+
+// ParseSalesforceTime parses the datetime, date and time formats returned by the REST API
+func ParseSalesforceTime(value string) (t time.Time, err error) {
+	for _, layout := range salesforceTimeLayouts {
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return
+		}
+	}
+	return t, fmt.Errorf("Unknown Salesforce time format: %q", value)
+}
+
+// DecodeRecord copies record into the struct pointed by out.
+//
+// Fields are matched by the soql tag, then the json tag, then the Go field name. A dotted tag
+// such as `soql:"Account.Owner.Name"` reads a parent relationship value; a struct field reads the
+// whole parent object, and a slice of structs reads a child relationship subquery. Null values
+// leave the field at its zero value (nil for pointers).
+func DecodeRecord(record ForceRecord, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("DecodeRecord needs a non-nil struct pointer, got %T", out)
+	}
+	plan, err := planFor(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return plan.decode(map[string]interface{}(record), rv.Elem())
+}
+
+// DecodeRecords decodes every record into a new T, which must be a struct type
+func DecodeRecords[T any](records []ForceRecord) ([]T, error) {
+	results := make([]T, len(records))
+	for i, record := range records {
+		if err := DecodeRecord(record, &results[i]); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
+
+// QueryInto runs query following every page and decodes the records into T
+func QueryInto[T any](f *Force, query string, options ...func(*QueryOptions)) (results []T, err error) {
+	queryOptions := QueryOptions{}
+	for _, option := range options {
+		option(&queryOptions)
+	}
+
+	res, err := f.Query(query, queryOptions.QueryAll, queryOptions.IsTooling)
+	if err != nil {
+		return
+	}
+
+	return DecodeRecords[T](res.Records)
+}
+
+// SelectInto runs a SOQLQuery and decodes the records into T. When the query has no fields,
+// the select list is derived from T as in SOQLFields.
+func SelectInto[T any](f *Force, q *SOQLQuery, options ...func(*QueryOptions)) (results []T, err error) {
+	if len(q.fields) == 0 && len(q.subqueries) == 0 {
+		if err = selectFieldsOf(q, reflect.TypeOf((*T)(nil)).Elem()); err != nil {
+			return
+		}
+	}
+
+	query, err := q.Build()
+	if err != nil {
+		return
+	}
+
+	return QueryInto[T](f, query, options...)
+}
+
+// NewSOQLQueryFor starts a query on sobject selecting the fields and child subqueries described by T
+func NewSOQLQueryFor[T any](sobject string) (q *SOQLQuery, err error) {
+	q = NewSOQLQuery(sobject)
+	err = selectFieldsOf(q, reflect.TypeOf((*T)(nil)).Elem())
+	return
+}
+
+// SOQLFields returns the field list of T in declaration order, parent relationships are
+// flattened with dots. Child relationships are not included, see NewSOQLQueryFor.
+func SOQLFields[T any]() ([]string, error) {
+	plan, err := planFor(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return plan.fieldNames(""), nil
+}
+
+func selectFieldsOf(q *SOQLQuery, t reflect.Type) error {
+	plan, err := planFor(t)
+	if err != nil {
+		return err
+	}
+	q.Select(plan.fieldNames("")...)
+	for _, field := range plan.fields {
+		if field.kind != decodeChildren {
+			continue
+		}
+		child := NewSOQLQuery(strings.Join(field.path, "."))
+		child.Select(field.children.fieldNames("")...)
+		q.SelectSubquery(child)
+	}
+	return nil
+}
+
+func planFor(t reflect.Type) (*decodePlan, error) {
+	return buildPlan(t, map[reflect.Type]bool{})
+}
+
+func buildPlan(t reflect.Type, visiting map[reflect.Type]bool) (*decodePlan, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Can not decode records into %v, a struct is required", t)
+	}
+	if cached, ok := decodePlans.Load(t); ok {
+		return cached.(*decodePlan), nil
+	}
+
+	if visiting[t] {
+		return nil, fmt.Errorf("Can not decode records into recursive type %v, use a soql tag path instead", t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	plan := &decodePlan{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := fieldName(sf)
+		if name == "-" {
+			continue
+		}
+
+		field := decodeField{index: sf.Index, path: strings.Split(name, ".")}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct && ft != timeType && ft != dateType:
+			children, err := buildPlan(ft, visiting)
+			if err != nil {
+				return nil, err
+			}
+			field.kind = decodeParent
+			field.children = children
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct && ft.Elem() != timeType && ft.Elem() != dateType:
+			children, err := buildPlan(ft.Elem(), visiting)
+			if err != nil {
+				return nil, err
+			}
+			field.kind = decodeChildren
+			field.children = children
+		}
+		plan.fields = append(plan.fields, field)
+	}
+
+	decodePlans.Store(t, plan)
+	return plan, nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if tag, ok := sf.Tag.Lookup("soql"); ok {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name
+		}
+	}
+	if tag, ok := sf.Tag.Lookup("json"); ok {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func (p *decodePlan) fieldNames(prefix string) (names []string) {
+	for _, field := range p.fields {
+		name := prefix + strings.Join(field.path, ".")
+		switch field.kind {
+		case decodeValue:
+			names = append(names, name)
+		case decodeParent:
+			names = append(names, field.children.fieldNames(name+".")...)
+		}
+	}
+	return
+}
+
+func (p *decodePlan) decode(record map[string]interface{}, out reflect.Value) error {
+	for _, field := range p.fields {
+		value, ok := lookupPath(record, field.path)
+		if !ok || value == nil {
+			continue
+		}
+		target := out.FieldByIndex(field.index)
+		name := strings.Join(field.path, ".")
+
+		switch field.kind {
+		case decodeParent:
+			parent, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: expected object, got %T", name, value)
+			}
+			dest := allocate(target)
+			if err := field.children.decode(parent, dest); err != nil {
+				return fmt.Errorf("%s.%w", name, err)
+			}
+		case decodeChildren:
+			rows, err := childRecords(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			slice := reflect.MakeSlice(target.Type(), len(rows), len(rows))
+			for i, row := range rows {
+				if err := field.children.decode(row, allocate(slice.Index(i))); err != nil {
+					return fmt.Errorf("%s[%d].%w", name, i, err)
+				}
+			}
+			target.Set(slice)
+		default:
+			if err := assignValue(target, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func lookupPath(record map[string]interface{}, path []string) (value interface{}, ok bool) {
+	current := record
+	for i, key := range path {
+		value, ok = current[key]
+		if !ok || i == len(path)-1 {
+			return
+		}
+		if current, ok = value.(map[string]interface{}); !ok {
+			return nil, value == nil
+		}
+	}
+	return
+}
+
+// childRecords reads a subquery result, which is a query result object holding a records array
+func childRecords(value interface{}) (rows []map[string]interface{}, err error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		items, _ = v["records"].([]interface{})
+	case []interface{}:
+		items = v
+	default:
+		return nil, fmt.Errorf("expected subquery result, got %T", value)
+	}
+	for _, item := range items {
+		row, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected record, got %T", item)
+		}
+		rows = append(rows, row)
+	}
+	return
+}
+
+// allocate follows and allocates pointers until it reaches an addressable non-pointer value
+func allocate(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func assignValue(target reflect.Value, value interface{}) error {
+	target = allocate(target)
+
+	switch target.Type() {
+	case timeType:
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(t))
+		return nil
+	case dateType:
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(Date{t}))
+		return nil
+	case forceRecord:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", value)
+		}
+		target.Set(reflect.ValueOf(ForceRecord(m)))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Interface:
+		target.Set(reflect.ValueOf(value))
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			target.SetString(v)
+		case float64:
+			target.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			target.SetString(strconv.FormatBool(v))
+		default:
+			return fmt.Errorf("can not decode %T into string", value)
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			target.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			target.SetBool(b)
+		default:
+			return fmt.Errorf("can not decode %T into bool", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		if target.OverflowInt(int64(n)) {
+			return fmt.Errorf("%v overflows %v", n, target.Type())
+		}
+		target.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 || target.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%v overflows %v", n, target.Type())
+		}
+		target.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		target.SetFloat(n)
+	case reflect.Slice:
+		// multi-select picklists arrive as a single string separated by semicolons
+		if s, ok := value.(string); ok && target.Type().Elem().Kind() == reflect.String {
+			parts := strings.Split(s, ";")
+			slice := reflect.MakeSlice(target.Type(), len(parts), len(parts))
+			for i, part := range parts {
+				slice.Index(i).SetString(part)
+			}
+			target.Set(slice)
+			return nil
+		}
+		return convertJSON(target, value)
+	default:
+		return convertJSON(target, value)
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("can not decode %T into number", value)
+}
+
+func toTime(value interface{}) (time.Time, error) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("can not decode %T into time", value)
+	}
+	return ParseSalesforceTime(s)
+}
+
+// convertJSON is the fallback for maps, slices and types implementing json.Unmarshaler
+func convertJSON(target reflect.Value, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target.Addr().Interface())
+}
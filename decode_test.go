@@ -0,0 +1,132 @@
+package gforce
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type decodeTestContact struct {
+	Id        string
+	LastName  string    `json:"LastName"`
+	OwnerName string    `soql:"Account.Owner.Name"`
+	Birthdate *Date     `soql:"Birthdate"`
+	Modified  time.Time `soql:"SystemModstamp"`
+	Employees int       `soql:"NumberOfEmployees"`
+	Active    *bool     `soql:"Active__c"`
+	Ignored   string    `soql:"-"`
+}
+
+type decodeTestParent struct {
+	Id   string
+	Name string
+}
+
+type decodeTestAccount struct {
+	Id       string
+	Name     string
+	Parent   *decodeTestParent
+	Contacts []decodeTestContact
+}
+
+func decodeTestRecord(t *testing.T, body string) ForceRecord {
+	var record ForceRecord
+	if err := json.Unmarshal([]byte(body), &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+	return record
+}
+
+func TestDecodeRecord(t *testing.T) {
+	active := true
+	birthdate := Date{time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)}
+	type decodeItem struct {
+		record string
+		out    interface{}
+		result interface{}
+		err    bool
+	}
+	table := []decodeItem{
+		{
+			record: `{"Id":"003A","LastName":"Silva","Account":{"Owner":{"Name":"Ana"}},"Birthdate":"1990-05-17",
+				"SystemModstamp":"2021-03-04T05:06:07.000+0000","NumberOfEmployees":12,"Active__c":true,"Ignored":"x"}`,
+			out: &decodeTestContact{},
+			result: &decodeTestContact{
+				Id:        "003A",
+				LastName:  "Silva",
+				OwnerName: "Ana",
+				Birthdate: &birthdate,
+				Modified:  time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+				Employees: 12,
+				Active:    &active,
+			},
+		},
+		{
+			record: `{"Id":"003B","Account":null,"Birthdate":null,"Active__c":null}`,
+			out:    &decodeTestContact{},
+			result: &decodeTestContact{Id: "003B"},
+		},
+		{
+			record: `{"Id":"001A","Name":"Acme","Parent":{"Id":"001P","Name":"Holding"},
+				"Contacts":{"totalSize":2,"done":true,"records":[{"Id":"003A","LastName":"Silva"},{"Id":"003B","LastName":"Souza"}]}}`,
+			out: &decodeTestAccount{},
+			result: &decodeTestAccount{
+				Id:       "001A",
+				Name:     "Acme",
+				Parent:   &decodeTestParent{Id: "001P", Name: "Holding"},
+				Contacts: []decodeTestContact{{Id: "003A", LastName: "Silva"}, {Id: "003B", LastName: "Souza"}},
+			},
+		},
+		{
+			record: `{"Id":"003C","NumberOfEmployees":"many"}`,
+			out:    &decodeTestContact{},
+			err:    true,
+		},
+		{
+			record: `{"Id":"003D"}`,
+			out:    decodeTestContact{},
+			err:    true,
+		},
+	}
+	for _, tt := range table {
+		err := DecodeRecord(decodeTestRecord(t, tt.record), tt.out)
+		if (err != nil) != tt.err {
+			t.Errorf("decode '%v' error '%v'", tt.record, err)
+			continue
+		}
+		if !tt.err {
+			// times are compared as instants, the parsed location depends on the local zone
+			if contact, ok := tt.out.(*decodeTestContact); ok {
+				expected := tt.result.(*decodeTestContact)
+				if !contact.Modified.Equal(expected.Modified) {
+					t.Errorf("invalid time '%v', expected '%v'", contact.Modified, expected.Modified)
+				}
+				contact.Modified = expected.Modified
+			}
+			if !reflect.DeepEqual(tt.out, tt.result) {
+				t.Errorf("invalid decoded record '%+v', expected '%+v'", tt.out, tt.result)
+			}
+		}
+	}
+}
+
+func TestQueryInto(t *testing.T) {
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/next" {
+			fmt.Fprint(w, `{"done":true,"totalSize":2,"records":[{"Id":"001B","Name":"Beta"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"done":false,"totalSize":2,"nextRecordsUrl":"/next","records":[{"Id":"001A","Name":"Acme"}]}`)
+	})
+	accounts, err := QueryInto[decodeTestAccount](f, "SELECT Id, Name FROM Account")
+	if err != nil {
+		t.Fatalf("query into: %v", err)
+	}
+	expected := []decodeTestAccount{{Id: "001A", Name: "Acme"}, {Id: "001B", Name: "Beta"}}
+	if !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("invalid records '%+v', expected '%+v'", accounts, expected)
+	}
+}
@@ -0,0 +1,358 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	DeltaUpsert DeltaChangeKind = "upsert"
+	DeltaDelete DeltaChangeKind = "delete"
+)
+
+const (
+	// replicationWindow is how far back getUpdated and getDeleted can go, kept below 30 days
+	// so that a run does not fall outside it while in progress
+	replicationWindow    = 29 * 24 * time.Hour
+	replicationTimestamp = "2006-01-02T15:04:05+00:00"
+	deltaIdsPerQuery     = 200
+)
+
+type DeltaChangeKind string
+
+// UpdatedResult lists the records changed in a getUpdated window
+type UpdatedResult struct {
+	Ids               []string `json:"ids"`
+	LatestDateCovered string   `json:"latestDateCovered"`
+}
+
+// DeletedResult lists the records deleted in a getDeleted window
+type DeletedResult struct {
+	DeletedRecords        []DeletedRecord `json:"deletedRecords"`
+	EarliestDateAvailable string          `json:"earliestDateAvailable"`
+	LatestDateCovered     string          `json:"latestDateCovered"`
+}
+
+type DeletedRecord struct {
+	Id          string `json:"id"`
+	DeletedDate string `json:"deletedDate"`
+}
+
+// DeltaChange is a record to upsert or an Id to delete downstream
+type DeltaChange struct {
+	Kind   DeltaChangeKind
+	Id     string
+	Record ForceRecord
+	Time   time.Time
+}
+
+// WatermarkStore persists the time up to which each object has been synchronized
+type WatermarkStore interface {
+	// Load returns the zero time when sobject was never synchronized
+	Load(ctx context.Context, sobject string) (time.Time, error)
+	Save(ctx context.Context, sobject string, watermark time.Time) error
+}
+
+// DeltaSyncOptions configures DeltaSync
+type DeltaSyncOptions struct {
+	SObject string
+	Fields  []string
+	// WatermarkField is SystemModstamp by default, LastModifiedDate for objects without it
+	WatermarkField string
+	Store          WatermarkStore
+	// Initial is used when the store has no watermark, the zero time loads every record
+	Initial time.Time
+}
+
+// FileWatermarkStore keeps the watermarks of every object in one JSON file
+type FileWatermarkStore struct {
+	mu   sync.Mutex
+	Path string
+}
+
+// GetUpdated returns the Ids of the records of sobject created or updated between start and end,
+// within the last 30 days
+func (f *Force) GetUpdated(sobject string, start, end time.Time) (result UpdatedResult, err error) {
+	body, err := f.httpGet(replicationURL(f, sobject, "updated", start, end), false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding updated records: %w", err)
+	}
+	return
+}
+
+// GetDeleted returns the records of sobject deleted between start and end, within the last 30 days
+func (f *Force) GetDeleted(sobject string, start, end time.Time) (result DeletedResult, err error) {
+	body, err := f.httpGet(replicationURL(f, sobject, "deleted", start, end), false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding deleted records: %w", err)
+	}
+	return
+}
+
+func replicationURL(f *Force, sobject, kind string, start, end time.Time) string {
+	return fmt.Sprintf("%s/services/data/%s/sobjects/%s/%s/?start=%s&end=%s", f.Credentials.InstanceUrl, apiVersion, sobject, kind,
+		url.QueryEscape(start.UTC().Format(replicationTimestamp)), url.QueryEscape(end.UTC().Format(replicationTimestamp)))
+}
+
+// DeltaSync emits the changes of opts.SObject since its stored watermark and saves the new
+// watermark. Watermarks older than 30 days query the watermark field with queryAll, changes are
+// emitted oldest first as pages arrive, IsDeleted records are reported as deletes and the
+// watermark is saved after each page. Windows within the last 30 days use getUpdated and
+// getDeleted: upserts are emitted by batches of Ids, then deletes oldest first, leaving out the
+// delete of a record upserted again at or after its DeletedDate, and the watermark is saved once
+// the window is emitted. A failed run replays the changes after the last saved watermark.
+func (f *Force) DeltaSync(ctx context.Context, opts DeltaSyncOptions, emit func(DeltaChange) error) (watermark time.Time, err error) {
+	if opts.WatermarkField == "" {
+		opts.WatermarkField = "SystemModstamp"
+	}
+
+	watermark = opts.Initial
+	if opts.Store != nil {
+		stored, e := opts.Store.Load(ctx, opts.SObject)
+		if e != nil {
+			return watermark, fmt.Errorf("opts.Store.Load(%s): %w", opts.SObject, e)
+		}
+		if !stored.IsZero() {
+			watermark = stored
+		}
+	}
+
+	emitChange := func(change DeltaChange) error {
+		if e := emit(change); e != nil {
+			return fmt.Errorf("emit(%s %s): %w", change.Kind, change.Id, e)
+		}
+		return nil
+	}
+	checkpoint := func(next time.Time) error {
+		if !next.After(watermark) {
+			return nil
+		}
+		if opts.Store != nil {
+			if e := opts.Store.Save(ctx, opts.SObject, next); e != nil {
+				return fmt.Errorf("opts.Store.Save(%s): %w", opts.SObject, e)
+			}
+		}
+		watermark = next
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if !watermark.IsZero() && now.Sub(watermark) < replicationWindow {
+		if now.Sub(watermark) < time.Minute {
+			// getUpdated and getDeleted work with minute precision
+			return watermark, nil
+		}
+		err = f.replicatedChanges(ctx, opts, watermark, now, emitChange, checkpoint)
+	} else {
+		err = f.queriedChanges(ctx, opts, watermark, emitChange, checkpoint)
+	}
+	return
+}
+
+func (f *Force) replicatedChanges(ctx context.Context, opts DeltaSyncOptions, start, end time.Time, emit func(DeltaChange) error, checkpoint func(time.Time) error) (err error) {
+	updated, err := f.GetUpdated(opts.SObject, start, end)
+	if err != nil {
+		return
+	}
+	deleted, err := f.GetDeleted(opts.SObject, start, end)
+	if err != nil {
+		return
+	}
+
+	// the next run starts where both windows are known to be complete
+	next, err := ParseSalesforceTime(updated.LatestDateCovered)
+	if err != nil {
+		return fmt.Errorf("latestDateCovered: %w", err)
+	}
+	if covered, e := ParseSalesforceTime(deleted.LatestDateCovered); e == nil && covered.Before(next) {
+		next = covered
+	}
+
+	// the Id batches are not in time order, the upsert times are kept to order deletes after them
+	upserted := map[string]time.Time{}
+	emitUpsert := func(change DeltaChange) error {
+		upserted[change.Id] = change.Time
+		return emit(change)
+	}
+	for i := 0; i < len(updated.Ids); i += deltaIdsPerQuery {
+		j := i + deltaIdsPerQuery
+		if j > len(updated.Ids) {
+			j = len(updated.Ids)
+		}
+		if err = f.deltaRecords(ctx, deltaQuery(opts).Where(In("Id", updated.Ids[i:j])), opts, false, emitUpsert, nil); err != nil {
+			return
+		}
+	}
+
+	deletes := make([]DeltaChange, 0, len(deleted.DeletedRecords))
+	for _, record := range deleted.DeletedRecords {
+		deletedAt, _ := ParseSalesforceTime(record.DeletedDate)
+		// a record undeleted after its delete was already emitted as an upsert
+		if modified, ok := upserted[record.Id]; ok && !modified.Before(deletedAt) {
+			continue
+		}
+		deletes = append(deletes, DeltaChange{Kind: DeltaDelete, Id: record.Id, Time: deletedAt})
+	}
+	sort.SliceStable(deletes, func(i, j int) bool {
+		return deletes[i].Time.Before(deletes[j].Time)
+	})
+	for _, change := range deletes {
+		if err = emit(change); err != nil {
+			return
+		}
+	}
+	return checkpoint(next)
+}
+
+func (f *Force) queriedChanges(ctx context.Context, opts DeltaSyncOptions, since time.Time, emit func(DeltaChange) error, checkpoint func(time.Time) error) (err error) {
+	q := deltaQuery(opts).OrderBy(opts.WatermarkField, Asc).OrderBy("Id", Asc)
+	if !since.IsZero() {
+		q.Where(Gt(opts.WatermarkField, since))
+	}
+	// the query is strictly after the watermark, so a page only completes the times before its
+	// last one: changes sharing that time may continue on the next page
+	var complete, last time.Time
+	err = f.deltaRecords(ctx, q, opts, !since.IsZero(), func(change DeltaChange) error {
+		if change.Time.After(last) {
+			complete, last = last, change.Time
+		}
+		return emit(change)
+	}, func() error {
+		return checkpoint(complete)
+	})
+	if err != nil {
+		return
+	}
+	return checkpoint(last)
+}
+
+// deltaQuery selects the requested fields plus Id and the watermark field
+func deltaQuery(opts DeltaSyncOptions) *SOQLQuery {
+	fields := append([]string{}, opts.Fields...)
+	for _, required := range []string{"Id", opts.WatermarkField} {
+		if !containsFold(fields, required) {
+			fields = append(fields, required)
+		}
+	}
+	return NewSOQLQuery(opts.SObject).Select(fields...)
+}
+
+// deltaRecords emits the changes of q page by page, with queryAll and IsDeleted selected when
+// deletes must be detected. pageDone, when set, runs after the changes of each page were emitted.
+func (f *Force) deltaRecords(ctx context.Context, q *SOQLQuery, opts DeltaSyncOptions, withDeleted bool, emit func(DeltaChange) error, pageDone func() error) (err error) {
+	options := []func(*QueryOptions){WithPrefetch}
+	selected := containsFold(opts.Fields, "IsDeleted")
+	if withDeleted {
+		if !selected {
+			q.Select("IsDeleted")
+		}
+		options = append(options, WithQueryAll)
+	}
+	query, err := q.Build()
+	if err != nil {
+		return
+	}
+	it := f.NewQueryIterator(ctx, query, options...)
+	defer it.Close()
+	for {
+		records, ok := it.Page()
+		if !ok {
+			break
+		}
+		for _, record := range records {
+			change := DeltaChange{Kind: DeltaUpsert, Id: cast.ToString(record["Id"]), Record: record}
+			if change.Time, err = ParseSalesforceTime(cast.ToString(record[opts.WatermarkField])); err != nil {
+				return fmt.Errorf("%s of %s: %w", opts.WatermarkField, change.Id, err)
+			}
+			if withDeleted {
+				if deleted, _ := record["IsDeleted"].(bool); deleted {
+					change.Kind = DeltaDelete
+					change.Record = nil
+				}
+				if !selected {
+					delete(record, "IsDeleted")
+				}
+			}
+			if err = emit(change); err != nil {
+				return
+			}
+		}
+		if pageDone != nil {
+			if err = pageDone(); err != nil {
+				return
+			}
+		}
+	}
+	return it.Err()
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewFileWatermarkStore(path string) *FileWatermarkStore {
+	return &FileWatermarkStore{Path: path}
+}
+
+func (s *FileWatermarkStore) read() (watermarks map[string]time.Time, err error) {
+	watermarks = map[string]time.Time{}
+	body, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return watermarks, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &watermarks)
+	return
+}
+
+func (s *FileWatermarkStore) Load(ctx context.Context, sobject string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watermarks, err := s.read()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return watermarks[sobject], nil
+}
+
+func (s *FileWatermarkStore) Save(ctx context.Context, sobject string, watermark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watermarks, err := s.read()
+	if err != nil {
+		return err
+	}
+	watermarks[sobject] = watermark
+	body, err := json.MarshalIndent(watermarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err = ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
@@ -0,0 +1,226 @@
+package gforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryWatermarkStore struct {
+	mu    sync.Mutex
+	saves []time.Time
+}
+
+func (s *memoryWatermarkStore) Load(ctx context.Context, sobject string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.saves) == 0 {
+		return time.Time{}, nil
+	}
+	return s.saves[len(s.saves)-1], nil
+}
+
+func (s *memoryWatermarkStore) Save(ctx context.Context, sobject string, watermark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves = append(s.saves, watermark)
+	return nil
+}
+
+// deltaChanges formats changes as "kind id" for comparison
+func deltaChanges(changes []DeltaChange) (formatted []string) {
+	for _, change := range changes {
+		formatted = append(formatted, fmt.Sprintf("%s %s", change.Kind, change.Id))
+	}
+	return
+}
+
+func TestDeltaSyncReplicated(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(minutes int) string {
+		return now.Add(time.Duration(minutes) * time.Minute).Format(salesforceDateTimeFormat)
+	}
+
+	var query string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sobjects/Account/updated/"):
+			fmt.Fprintf(w, `{"ids":["001A","001B"],"latestDateCovered":"%s"}`, at(-5))
+		case strings.HasSuffix(r.URL.Path, "/sobjects/Account/deleted/"):
+			fmt.Fprintf(w, `{"deletedRecords":[{"id":"001D","deletedDate":"%s"},{"id":"001B","deletedDate":"%s"},{"id":"001C","deletedDate":"%s"}],"latestDateCovered":"%s"}`,
+				at(-30), at(-50), at(-40), at(-10))
+		case strings.HasSuffix(r.URL.Path, "/query"):
+			query = r.URL.Query().Get("q")
+			fmt.Fprintf(w, `{"done":true,"totalSize":2,"records":[{"Id":"001A","SystemModstamp":"%s"},{"Id":"001B","SystemModstamp":"%s"}]}`, at(-60), at(-20))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	store := NewFileWatermarkStore(filepath.Join(t.TempDir(), "watermarks.json"))
+	var changes []DeltaChange
+	watermark, err := f.DeltaSync(context.Background(), DeltaSyncOptions{
+		SObject: "Account",
+		Fields:  []string{"Id"},
+		Store:   store,
+		Initial: now.Add(-2 * time.Hour),
+	}, func(change DeltaChange) error {
+		changes = append(changes, change)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DeltaSync error '%v'", err)
+	}
+
+	if expected := "SELECT Id, SystemModstamp FROM Account WHERE Id IN ('001A', '001B')"; query != expected {
+		t.Errorf("query '%v', expected '%v'", query, expected)
+	}
+	// the delete of 001B precedes its upsert, so only the upsert is kept
+	expected := []string{"upsert 001A", "upsert 001B", "delete 001C", "delete 001D"}
+	if formatted := deltaChanges(changes); !reflect.DeepEqual(formatted, expected) {
+		t.Errorf("changes '%v', expected '%v'", formatted, expected)
+	}
+
+	covered := now.Add(-10 * time.Minute)
+	if !watermark.Equal(covered) {
+		t.Errorf("watermark '%v', expected '%v'", watermark, covered)
+	}
+	if stored, _ := store.Load(context.Background(), "Account"); !stored.Equal(covered) {
+		t.Errorf("stored watermark '%v', expected '%v'", stored, covered)
+	}
+}
+
+func TestDeltaSyncQueryAll(t *testing.T) {
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	t3 := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	type queryAllItem struct {
+		failSecondPage bool
+		changes        []string
+		saves          []time.Time
+	}
+	table := []queryAllItem{
+		{
+			changes: []string{"upsert 001A", "upsert 001B", "delete 001C", "upsert 001D"},
+			saves:   []time.Time{t1, t2, t3},
+		},
+		{
+			failSecondPage: true,
+			changes:        []string{"upsert 001A", "upsert 001B"},
+			saves:          []time.Time{t1},
+		},
+	}
+	for _, tt := range table {
+		var path, query string
+		f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/queryAll/01g-2") {
+				if tt.failSecondPage {
+					w.WriteHeader(http.StatusInternalServerError)
+					fmt.Fprint(w, `[{"message":"server error","errorCode":"UNKNOWN_EXCEPTION"}]`)
+					return
+				}
+				fmt.Fprintf(w, `{"done":true,"totalSize":4,"records":[{"Id":"001C","SystemModstamp":"%s","IsDeleted":true},{"Id":"001D","SystemModstamp":"%s","IsDeleted":false}]}`,
+					t2.Format(salesforceDateTimeFormat), t3.Format(salesforceDateTimeFormat))
+				return
+			}
+			path, query = r.URL.Path, r.URL.Query().Get("q")
+			fmt.Fprintf(w, `{"done":false,"totalSize":4,"nextRecordsUrl":"/services/data/%s/queryAll/01g-2","records":[{"Id":"001A","SystemModstamp":"%s","IsDeleted":false},{"Id":"001B","SystemModstamp":"%s","IsDeleted":false}]}`,
+				apiVersion, t1.Format(salesforceDateTimeFormat), t2.Format(salesforceDateTimeFormat))
+		})
+
+		store := &memoryWatermarkStore{}
+		var changes []DeltaChange
+		watermark, err := f.DeltaSync(context.Background(), DeltaSyncOptions{
+			SObject: "Account",
+			Fields:  []string{"Id"},
+			Store:   store,
+			Initial: since,
+		}, func(change DeltaChange) error {
+			changes = append(changes, change)
+			return nil
+		})
+		if (err != nil) != tt.failSecondPage {
+			t.Errorf("DeltaSync error '%v'", err)
+		}
+
+		if !strings.HasSuffix(path, "/queryAll") {
+			t.Errorf("path '%v', expected queryAll", path)
+		}
+		if expected := "SELECT Id, SystemModstamp, IsDeleted FROM Account WHERE SystemModstamp > 2020-01-01T00:00:00Z ORDER BY SystemModstamp ASC, Id ASC"; query != expected {
+			t.Errorf("query '%v', expected '%v'", query, expected)
+		}
+		if formatted := deltaChanges(changes); !reflect.DeepEqual(formatted, tt.changes) {
+			t.Errorf("changes '%v', expected '%v'", formatted, tt.changes)
+		}
+		for _, change := range changes {
+			if _, ok := change.Record["IsDeleted"]; ok {
+				t.Errorf("IsDeleted left in the record of %v", change.Id)
+			}
+			if change.Kind == DeltaDelete && change.Record != nil {
+				t.Errorf("record '%v' emitted with the delete of %v", change.Record, change.Id)
+			}
+		}
+		if !reflect.DeepEqual(store.saves, tt.saves) {
+			t.Errorf("saved watermarks '%v', expected '%v'", store.saves, tt.saves)
+		}
+		if last := tt.saves[len(tt.saves)-1]; !watermark.Equal(last) {
+			t.Errorf("watermark '%v', expected '%v'", watermark, last)
+		}
+	}
+}
+
+func TestDeltaSyncEmitError(t *testing.T) {
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"done":true,"totalSize":1,"records":[{"Id":"001A","SystemModstamp":"2020-02-01T00:00:00.000+0000"}]}`)
+	})
+	store := &memoryWatermarkStore{}
+	failed := errors.New("downstream unavailable")
+	_, err := f.DeltaSync(context.Background(), DeltaSyncOptions{SObject: "Account", Store: store}, func(change DeltaChange) error {
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("DeltaSync error '%v', expected '%v'", err, failed)
+	}
+	if len(store.saves) != 0 {
+		t.Errorf("watermarks '%v' saved after a failed emit", store.saves)
+	}
+}
+
+func TestFileWatermarkStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watermarks.json")
+	store := NewFileWatermarkStore(path)
+
+	if watermark, err := store.Load(context.Background(), "Account"); err != nil || !watermark.IsZero() {
+		t.Errorf("missing file loaded '%v', '%v', expected the zero time", watermark, err)
+	}
+
+	account := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	contact := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	if err := store.Save(context.Background(), "Account", account); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(context.Background(), "Contact", contact); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewFileWatermarkStore(path)
+	for sobject, expected := range map[string]time.Time{"Account": account, "Contact": contact, "Lead": {}} {
+		watermark, err := reopened.Load(context.Background(), sobject)
+		if err != nil || !watermark.Equal(expected) {
+			t.Errorf("%s watermark '%v', '%v', expected '%v'", sobject, watermark, err, expected)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: '%v'", err)
+	}
+}
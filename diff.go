@@ -0,0 +1,192 @@
+package gforce
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var recordIdPattern = regexp.MustCompile(`^[a-zA-Z0-9]{15}([A-Z0-5]{3})?$`)
+
+// UpdateChangedResult is the outcome of UpdateChanged. Skipped is set when no field changed
+// and no request was sent.
+type UpdateChangedResult struct {
+	Changed ForceRecord
+	Skipped bool
+}
+
+// DiffRecord returns the fields of desired whose value differs from current. Field names are
+// matched case-insensitively and values compared after normalization: 15 and 18 character Ids
+// of the same record in Id and standard lookup fields (named like OwnerId), numbers however
+// formatted (1.5, "1.50", Decimal), datetimes to the second whatever their format, and null and
+// "" are equal. A field missing from current has changed.
+func DiffRecord(current, desired ForceRecord) (changed ForceRecord, err error) {
+	normalizedCurrent, err := normalizeRecord(current)
+	if err != nil {
+		return
+	}
+	normalizedDesired, err := normalizeRecord(desired)
+	if err != nil {
+		return
+	}
+	changed = ForceRecord{}
+	for name, value := range normalizedDesired {
+		if name == "attributes" {
+			continue
+		}
+		if existing, ok := lookupFold(normalizedCurrent, name); ok && equalFieldValues(name, existing, value) {
+			continue
+		}
+		changed[name] = desired[name]
+	}
+	return
+}
+
+// UpdateChanged updates only the fields of desired, a map or a struct (see EncodeRecord), that
+// differ from the record. current is the state to compare with; when nil, the fields of desired
+// are read from the record first. id is either a record Id or externalIdField:value.
+func (f *Force) UpdateChanged(sobject, id string, desired interface{}, current ForceRecord) (result UpdateChangedResult, err error) {
+	values, err := EncodeRecord(desired)
+	if err != nil {
+		return
+	}
+	delete(values, "Id")
+	if current == nil {
+		fields := make([]string, 0, len(values))
+		for name := range values {
+			fields = append(fields, name)
+		}
+		if len(fields) > 0 {
+			var body []byte
+			if body, err = f.httpGet(recordURL(f, sobject, id)+"?fields="+strings.Join(fields, ","), false); err != nil {
+				return
+			}
+			if err = json.Unmarshal(body, &current); err != nil {
+				return
+			}
+		}
+	}
+
+	if result.Changed, err = DiffRecord(current, values); err != nil {
+		return
+	}
+	if len(result.Changed) == 0 {
+		result.Skipped = true
+		return
+	}
+	data, err := json.Marshal(result.Changed)
+	if err != nil {
+		return
+	}
+	_, err = f.httpPatchJSON(recordURL(f, sobject, id), string(data), false)
+	return
+}
+
+// normalizeRecord round trips record through JSON so that typed values (time.Time, Date,
+// Decimal...) compare as they are sent, with numbers kept as json.Number
+func normalizeRecord(record ForceRecord) (normalized ForceRecord, err error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&normalized)
+	return
+}
+
+func lookupFold(record ForceRecord, name string) (interface{}, bool) {
+	if value, ok := record[name]; ok {
+		return value, true
+	}
+	for key, value := range record {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func equalFieldValues(name string, a, b interface{}) bool {
+	if a == "" {
+		a = nil
+	}
+	if b == "" {
+		b = nil
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	_, aNumber := a.(json.Number)
+	_, bNumber := b.(json.Number)
+	if aNumber || bNumber {
+		x, okA := decimalValue(a)
+		y, okB := decimalValue(b)
+		return okA && okB && x.Cmp(y) == 0
+	}
+	as, aString := a.(string)
+	bs, bString := b.(string)
+	if aString && bString {
+		if as == bs {
+			return true
+		}
+		if isIdField(name) && recordIdPattern.MatchString(as) && recordIdPattern.MatchString(bs) {
+			return recordId18(as) == recordId18(bs)
+		}
+		at, errA := ParseSalesforceTime(as)
+		bt, errB := ParseSalesforceTime(bs)
+		if errA == nil && errB == nil {
+			return at.Truncate(time.Second).Equal(bt.Truncate(time.Second))
+		}
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// isIdField reports whether name holds record Ids: Id and the standard lookups such as OwnerId.
+// Other fields may hold 15 or 18 character codes that are not Ids.
+func isIdField(name string) bool {
+	return strings.EqualFold(name, "Id") || strings.HasSuffix(name, "Id")
+}
+
+// decimalValue reads a json.Number, or a string holding a number compared with one, exactly
+func decimalValue(value interface{}) (*big.Rat, bool) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		if !decimalPattern.MatchString(v) {
+			return nil, false
+		}
+		s = v
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// recordId18 returns the case-insensitive 18 character form of a 15 character Id
+func recordId18(id string) string {
+	if len(id) == 18 {
+		return id
+	}
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"
+	suffix := make([]byte, 3)
+	for block := 0; block < 3; block++ {
+		flags := 0
+		for i := 0; i < 5; i++ {
+			c := id[block*5+i]
+			if c >= 'A' && c <= 'Z' {
+				flags |= 1 << i
+			}
+		}
+		suffix[block] = alphabet[flags]
+	}
+	return id + string(suffix)
+}
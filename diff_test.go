@@ -0,0 +1,83 @@
+package gforce
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordId18(t *testing.T) {
+	type idItem struct {
+		id   string
+		id18 string
+	}
+	table := []idItem{
+		{id: "001D000000IqhSL", id18: "001D000000IqhSLIAZ"},
+		{id: "001d000000iqhsl", id18: "001d000000iqhslAAA"},
+		{id: "003ABCDEABCDEAB", id18: "003ABCDEABCDEABY55"},
+		{id: "001D000000IqhSLIAZ", id18: "001D000000IqhSLIAZ"},
+	}
+	for _, tt := range table {
+		if id18 := recordId18(tt.id); id18 != tt.id18 {
+			t.Errorf("18 character id of '%v': '%v', expected '%v'", tt.id, id18, tt.id18)
+		}
+	}
+}
+
+func TestDiffRecord(t *testing.T) {
+	type diffItem struct {
+		current string
+		desired ForceRecord
+		changed ForceRecord
+	}
+	table := []diffItem{
+		{
+			current: `{"attributes":{"type":"Account"},"Name":"Acme","OwnerId":"005D000000IqhSLIAZ","AnnualRevenue":1.5,"Description":null}`,
+			desired: ForceRecord{"name": "Acme", "OwnerId": "005D000000IqhSL", "AnnualRevenue": Decimal("1.50"), "Description": ""},
+			changed: ForceRecord{},
+		},
+		{
+			current: `{"Name":"Acme","NumberOfEmployees":10,"Site":"HQ"}`,
+			desired: ForceRecord{"Name": "Acme Inc", "NumberOfEmployees": 11, "Site": nil, "Phone": "123"},
+			changed: ForceRecord{"Name": "Acme Inc", "NumberOfEmployees": 11, "Site": nil, "Phone": "123"},
+		},
+		{
+			current: `{"Reviewed__c":"2021-03-04T08:06:07.000+0000","Founded__c":"1990-05-17"}`,
+			desired: ForceRecord{
+				"Reviewed__c": time.Date(2021, 3, 4, 5, 6, 7, 400, time.FixedZone("BRT", -3*60*60)),
+				"Founded__c":  DateOf(time.Date(1990, 5, 18, 0, 0, 0, 0, time.UTC)),
+			},
+			changed: ForceRecord{"Founded__c": DateOf(time.Date(1990, 5, 18, 0, 0, 0, 0, time.UTC))},
+		},
+		{
+			current: `{"Id":"001D000000IqhSL","ParentId":"001D000000IqhSLIAZ","Code__c":"001D000000IqhSL"}`,
+			desired: ForceRecord{"Id": "001D000000IqhSLIAZ", "ParentId": "001D000000IqhSL", "Code__c": "001D000000IqhSLIAZ"},
+			changed: ForceRecord{"Code__c": "001D000000IqhSLIAZ"},
+		},
+		{
+			current: `{"OwnerId":"005D000000IqhSL"}`,
+			desired: ForceRecord{"OwnerId": "005D000000IqhSLAAA"},
+			changed: ForceRecord{"OwnerId": "005D000000IqhSLAAA"},
+		},
+		{
+			current: `{"Code__c":"007","Amount__c":"10"}`,
+			desired: ForceRecord{"Code__c": "7", "Amount__c": 10},
+			changed: ForceRecord{"Code__c": "7"},
+		},
+	}
+	for i, tt := range table {
+		var current ForceRecord
+		if err := json.Unmarshal([]byte(tt.current), &current); err != nil {
+			t.Fatalf("unmarshal record: %v", err)
+		}
+		changed, err := DiffRecord(current, tt.desired)
+		if err != nil {
+			t.Errorf("%d: diff: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(changed, tt.changed) {
+			t.Errorf("%d: invalid changes '%v', expected '%v'", i, changed, tt.changed)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultLargeObjectCardinality is the object size from which CheckSelectivity requires a selective plan
+const DefaultLargeObjectCardinality = 200000
+
+var NonSelectiveQueryError = errors.New("Non-selective query")
+
+// QueryPlanNote explains why an index could not be used
+type QueryPlanNote struct {
+	Description   string   `json:"description"`
+	Fields        []string `json:"fields"`
+	TableEnumOrId string   `json:"tableEnumOrId"`
+}
+
+// QueryPlan is one of the plans the query optimizer considered
+type QueryPlan struct {
+	Cardinality          int64           `json:"cardinality"`
+	Fields               []string        `json:"fields"`
+	LeadingOperationType string          `json:"leadingOperationType"`
+	Notes                []QueryPlanNote `json:"notes"`
+	RelativeCost         float64         `json:"relativeCost"`
+	SObjectCardinality   int64           `json:"sobjectCardinality"`
+	SObjectType          string          `json:"sobjectType"`
+}
+
+// QueryExplainResult lists the plans of a query, cheapest first
+type QueryExplainResult struct {
+	Plans       []QueryPlan `json:"plans"`
+	SourceQuery string      `json:"sourceQuery"`
+}
+
+// Selective reports whether the optimizer considers the plan selective, a relative cost above 1 is not
+func (p QueryPlan) Selective() bool {
+	return p.RelativeCost <= 1
+}
+
+// Best returns the plan the optimizer would run, the one with the lowest relative cost
+func (r QueryExplainResult) Best() (plan QueryPlan, ok bool) {
+	if len(r.Plans) == 0 {
+		return
+	}
+	return r.Plans[0], true
+}
+
+// ExplainQuery returns the query plans of a SOQL query without running it
+func (f *Force) ExplainQuery(query string) (result QueryExplainResult, err error) {
+	return f.explain(query)
+}
+
+// ExplainListView returns the query plans of the query behind a list view
+func (f *Force) ExplainListView(listViewID string) (result QueryExplainResult, err error) {
+	return f.explain(listViewID)
+}
+
+func (f *Force) explain(subject string) (result QueryExplainResult, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/query?explain=%s", f.Credentials.InstanceUrl, apiVersion, url.QueryEscape(subject))
+	body, err := f.httpGet(url, false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding query plan: %w", err)
+		return
+	}
+	sort.SliceStable(result.Plans, func(i, j int) bool {
+		return result.Plans[i].RelativeCost < result.Plans[j].RelativeCost
+	})
+	return
+}
+
+// CheckSelectivity explains query and returns a NonSelectiveQueryError when the best plan is not
+// selective and the object holds at least largeObject records (DefaultLargeObjectCardinality
+// when 0). Call it before Select or Query on objects that may time out.
+func (f *Force) CheckSelectivity(query string, largeObject int64) (plan QueryPlan, err error) {
+	if largeObject <= 0 {
+		largeObject = DefaultLargeObjectCardinality
+	}
+	result, err := f.ExplainQuery(query)
+	if err != nil {
+		return
+	}
+	plan, ok := result.Best()
+	if !ok {
+		return plan, fmt.Errorf("No query plan for %s", query)
+	}
+	if plan.SObjectCardinality >= largeObject && !plan.Selective() {
+		var notes []string
+		for _, note := range plan.Notes {
+			notes = append(notes, note.Description)
+		}
+		err = fmt.Errorf("%w: %s on %s (%d records, relative cost %.2f) %s", NonSelectiveQueryError,
+			plan.LeadingOperationType, plan.SObjectType, plan.SObjectCardinality, plan.RelativeCost, strings.Join(notes, "; "))
+	}
+	return
+}
+
+// CheckSelectivityQuery builds q and checks it with CheckSelectivity
+func (f *Force) CheckSelectivityQuery(q *SOQLQuery, largeObject int64) (plan QueryPlan, err error) {
+	query, err := q.Build()
+	if err != nil {
+		return
+	}
+	return f.CheckSelectivity(query, largeObject)
+}
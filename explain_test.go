@@ -0,0 +1,68 @@
+package gforce
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCheckSelectivity(t *testing.T) {
+	const (
+		selectivePlan    = `{"cardinality":10,"fields":["Name"],"leadingOperationType":"Index","notes":[],"relativeCost":0.2,"sobjectCardinality":%d,"sobjectType":"Account"}`
+		nonSelectivePlan = `{"cardinality":%d,"fields":[],"leadingOperationType":"TableScan","notes":[{"description":"Not considering filter for optimization because unindexed","fields":["Site"],"tableEnumOrId":"Account"}],"relativeCost":2.5,"sobjectCardinality":%d,"sobjectType":"Account"}`
+	)
+
+	type selectivityItem struct {
+		plans       string
+		largeObject int64
+		operation   string
+		err         error
+	}
+	table := []selectivityItem{
+		{
+			plans:     fmt.Sprintf(nonSelectivePlan+","+selectivePlan, 500000, 500000, 500000),
+			operation: "Index",
+		},
+		{
+			plans:     fmt.Sprintf(nonSelectivePlan, 500000, 500000),
+			operation: "TableScan",
+			err:       NonSelectiveQueryError,
+		},
+		{
+			plans:     fmt.Sprintf(nonSelectivePlan, 1000, 1000),
+			operation: "TableScan",
+		},
+		{
+			plans:       fmt.Sprintf(nonSelectivePlan, 1000, 1000),
+			largeObject: 500,
+			operation:   "TableScan",
+			err:         NonSelectiveQueryError,
+		},
+	}
+	for _, tt := range table {
+		var explain string
+		f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+			explain = r.URL.Query().Get("explain")
+			fmt.Fprintf(w, `{"plans":[%s],"sourceQuery":"SELECT Id FROM Account WHERE Site = 'x'"}`, tt.plans)
+		})
+
+		plan, err := f.CheckSelectivityQuery(NewSOQLQuery("Account").Select("Id").Where(Eq("Site", "x")), tt.largeObject)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("selectivity error '%v', expected '%v'", err, tt.err)
+		}
+		if plan.LeadingOperationType != tt.operation {
+			t.Errorf("best plan '%v', expected '%v'", plan.LeadingOperationType, tt.operation)
+		}
+		if expected := "SELECT Id FROM Account WHERE Site = 'x'"; explain != expected {
+			t.Errorf("explained '%v', expected '%v'", explain, expected)
+		}
+	}
+
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"plans":[],"sourceQuery":"SELECT Id FROM Account"}`)
+	})
+	if _, err := f.CheckSelectivity("SELECT Id FROM Account", 0); err == nil {
+		t.Error("no error without query plans")
+	}
+}
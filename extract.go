@@ -0,0 +1,460 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	ChunkByID ExtractChunkBy = iota
+	ChunkByCreatedDate
+)
+
+const (
+	base62Alphabet      = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	defaultExtractChunk = 8
+	defaultExtractPool  = 4
+)
+
+var ApiLimitReachedError = errors.New("API request limit reached")
+
+type ExtractChunkBy int
+
+// ExtractOptions configures ParallelExtract
+type ExtractOptions struct {
+	SObject string
+	Fields  []string
+	Where   []SOQLCondition
+	// ChunkBy selects Id or CreatedDate ranges
+	ChunkBy ExtractChunkBy
+	// Chunks is the number of ranges, defaults to 8
+	Chunks int
+	// Workers bounds the concurrent queries, defaults to 4
+	Workers int
+	// KeyPrefix splits the whole Id space of a key prefix (e.g. 001) instead of sampling
+	// the lowest and highest Id of the object
+	KeyPrefix string
+	// From and To bound CreatedDate chunking, sampled from the object when zero
+	From time.Time
+	To   time.Time
+	// QueryAll includes deleted and archived records
+	QueryAll bool
+	// MinRemainingRequests stops starting chunks when DailyApiRequests falls below it, 0 disables the check
+	MinRemainingRequests int64
+	// Progress persists chunk progress under JobID so a failed extract can resume
+	Progress ExtractProgressStore
+	JobID    string
+}
+
+// ExtractChunk is a range of an extract and its progress
+type ExtractChunk struct {
+	Index     int    `json:"index"`
+	Lower     string `json:"lower"`
+	Upper     string `json:"upper,omitempty"`
+	ResumeURL string `json:"resumeUrl,omitempty"`
+	Records   int    `json:"records"`
+	Done      bool   `json:"done"`
+}
+
+// ExtractProgressStore saves the chunks of an extract between runs
+type ExtractProgressStore interface {
+	Load(ctx context.Context, jobID string) ([]ExtractChunk, error)
+	Save(ctx context.Context, jobID string, chunks []ExtractChunk) error
+}
+
+// FileProgressStore keeps extract progress as JSON files in a directory
+type FileProgressStore struct {
+	Dir string
+}
+
+// ParallelExtract splits the query described by opts into Id or CreatedDate ranges and runs them
+// concurrently, writing every page to sink. Pages of different chunks are interleaved and
+// WritePage is called from several workers at once, so sink must be safe for concurrent use.
+// When opts.Progress holds chunks for opts.JobID, finished chunks are skipped and the others
+// resume from their last page; records of a page in flight when the run stopped are written again.
+func (f *Force) ParallelExtract(ctx context.Context, opts ExtractOptions, sink QuerySink) (chunks []ExtractChunk, err error) {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultExtractPool
+	}
+
+	if opts.Progress != nil && opts.JobID != "" {
+		if chunks, err = opts.Progress.Load(ctx, opts.JobID); err != nil {
+			return nil, fmt.Errorf("opts.Progress.Load(): %w", err)
+		}
+	}
+	if len(chunks) == 0 {
+		if chunks, err = f.ExtractChunks(ctx, opts); err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		pages    int
+		queue    = make(chan int)
+	)
+
+	fail := func(e error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = e
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	// save runs with mu held
+	save := func() {
+		if opts.Progress == nil || opts.JobID == "" {
+			return
+		}
+		snapshot := append([]ExtractChunk(nil), chunks...)
+		if e := opts.Progress.Save(ctx, opts.JobID, snapshot); e != nil && firstErr == nil {
+			firstErr = fmt.Errorf("opts.Progress.Save(): %w", e)
+			cancel()
+		}
+	}
+
+	worker := func() {
+		defer wg.Done()
+		for i := range queue {
+			mu.Lock()
+			chunk := chunks[i]
+			mu.Unlock()
+
+			it, e := f.chunkIterator(ctx, opts, chunk)
+			if e != nil {
+				fail(e)
+				continue
+			}
+			for {
+				records, ok := it.Page()
+				if !ok {
+					break
+				}
+				mu.Lock()
+				index := pages
+				pages++
+				mu.Unlock()
+
+				if _, e = sink.WritePage(ctx, QueryPage{
+					Index:          index,
+					Records:        records,
+					TotalSize:      it.TotalSize(),
+					NextRecordsURL: it.NextRecordsURL(),
+				}); e != nil {
+					e = fmt.Errorf("sink.WritePage(): %w", e)
+					break
+				}
+
+				mu.Lock()
+				chunks[i].Records += len(records)
+				chunks[i].ResumeURL = it.ResumeURL()
+				save()
+				mu.Unlock()
+			}
+			if e == nil {
+				if e = it.Err(); e != nil {
+					e = fmt.Errorf("chunk %d: %w", chunk.Index, e)
+				}
+			}
+			it.Close()
+			if e != nil {
+				fail(e)
+				continue
+			}
+
+			mu.Lock()
+			chunks[i].Done = true
+			chunks[i].ResumeURL = ""
+			save()
+			mu.Unlock()
+		}
+	}
+
+	wg.Add(opts.Workers)
+	for w := 0; w < opts.Workers; w++ {
+		go worker()
+	}
+
+dispatch:
+	for i := range chunks {
+		if chunks[i].Done {
+			continue
+		}
+		if e := f.checkApiBudget(opts.MinRemainingRequests); e != nil {
+			fail(e)
+			break
+		}
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if e := sink.Close(); e != nil && firstErr == nil {
+		firstErr = fmt.Errorf("sink.Close(): %w", e)
+	}
+
+	return chunks, firstErr
+}
+
+// ExtractChunks computes the ranges ParallelExtract would run, without running them
+func (f *Force) ExtractChunks(ctx context.Context, opts ExtractOptions) (chunks []ExtractChunk, err error) {
+	if opts.Chunks <= 0 {
+		opts.Chunks = defaultExtractChunk
+	}
+
+	var bounds []string
+	switch opts.ChunkBy {
+	case ChunkByID:
+		bounds, err = f.idBounds(ctx, opts)
+	case ChunkByCreatedDate:
+		bounds, err = f.createdDateBounds(ctx, opts)
+	default:
+		err = fmt.Errorf("Unknown chunking %d", opts.ChunkBy)
+	}
+	if err != nil || len(bounds) == 0 {
+		return
+	}
+
+	for i := 0; i < len(bounds)-1; i++ {
+		chunks = append(chunks, ExtractChunk{Index: i, Lower: bounds[i], Upper: bounds[i+1]})
+	}
+	// the last range is open so the highest value is included
+	chunks[len(chunks)-1].Upper = ""
+
+	return
+}
+
+func (f *Force) chunkIterator(ctx context.Context, opts ExtractOptions, chunk ExtractChunk) (*QueryIterator, error) {
+	options := []func(*QueryOptions){WithPrefetch}
+	if opts.QueryAll {
+		options = append(options, WithQueryAll)
+	}
+	if chunk.ResumeURL != "" {
+		return f.ResumeQueryIterator(ctx, chunk.ResumeURL, options...), nil
+	}
+
+	field := "Id"
+	var lower, upper interface{} = chunk.Lower, chunk.Upper
+	if opts.ChunkBy == ChunkByCreatedDate {
+		field = "CreatedDate"
+		from, err := time.Parse(time.RFC3339, chunk.Lower)
+		if err != nil {
+			return nil, err
+		}
+		lower = from
+		if chunk.Upper != "" {
+			to, err := time.Parse(time.RFC3339, chunk.Upper)
+			if err != nil {
+				return nil, err
+			}
+			upper = to
+		}
+	}
+
+	conditions := append([]SOQLCondition{}, opts.Where...)
+	conditions = append(conditions, Ge(field, lower))
+	if chunk.Upper != "" {
+		conditions = append(conditions, Lt(field, upper))
+	}
+	query, err := NewSOQLQuery(opts.SObject).Select(opts.Fields...).Where(conditions...).Build()
+	if err != nil {
+		return nil, err
+	}
+	return f.NewQueryIterator(ctx, query, options...), nil
+}
+
+func (f *Force) checkApiBudget(minRemaining int64) error {
+	if minRemaining <= 0 {
+		return nil
+	}
+	limits, err := f.GetLimits()
+	if err != nil {
+		return fmt.Errorf("f.GetLimits(): %w", err)
+	}
+	if limit, ok := limits["DailyApiRequests"]; ok && limit.Remaining < minRemaining {
+		return fmt.Errorf("%w: %d of %d daily requests remaining", ApiLimitReachedError, limit.Remaining, limit.Max)
+	}
+	return nil
+}
+
+// sampleEdge returns the lowest (Asc) or highest (Desc) value of field matching opts
+func (f *Force) sampleEdge(ctx context.Context, opts ExtractOptions, field string, order SOQLOrder) (value string, found bool, err error) {
+	query, err := NewSOQLQuery(opts.SObject).Select(field).Where(opts.Where...).OrderBy(field, order).Limit(1).Build()
+	if err != nil {
+		return
+	}
+	options := []func(*QueryOptions){}
+	if opts.QueryAll {
+		options = append(options, WithQueryAll)
+	}
+	it := f.NewQueryIterator(ctx, query, options...)
+	defer it.Close()
+	if it.Next() {
+		return cast.ToString(it.Record()[field]), true, nil
+	}
+	return "", false, it.Err()
+}
+
+func (f *Force) idBounds(ctx context.Context, opts ExtractOptions) (bounds []string, err error) {
+	var low, high string
+	if opts.KeyPrefix != "" {
+		if len(opts.KeyPrefix) != 3 {
+			return nil, fmt.Errorf("Invalid key prefix %q", opts.KeyPrefix)
+		}
+		low = opts.KeyPrefix + strings.Repeat("0", 12)
+		high = opts.KeyPrefix + strings.Repeat("z", 12)
+	} else {
+		var found bool
+		if low, found, err = f.sampleEdge(ctx, opts, "Id", Asc); err != nil || !found {
+			return
+		}
+		if high, _, err = f.sampleEdge(ctx, opts, "Id", Desc); err != nil {
+			return
+		}
+	}
+
+	lowN, err := decodeBase62(low[:15])
+	if err != nil {
+		return nil, err
+	}
+	highN, err := decodeBase62(high[:15])
+	if err != nil {
+		return nil, err
+	}
+
+	step := new(big.Int).Sub(highN, lowN)
+	step.Div(step, big.NewInt(int64(opts.Chunks)))
+	if step.Sign() == 0 {
+		return []string{low[:15], ""}, nil
+	}
+
+	current := new(big.Int).Set(lowN)
+	for i := 0; i < opts.Chunks; i++ {
+		bounds = append(bounds, encodeBase62(current, 15))
+		current.Add(current, step)
+	}
+	bounds = append(bounds, "")
+
+	return
+}
+
+func (f *Force) createdDateBounds(ctx context.Context, opts ExtractOptions) (bounds []string, err error) {
+	from, to := opts.From, opts.To
+	if from.IsZero() {
+		value, found, e := f.sampleEdge(ctx, opts, "CreatedDate", Asc)
+		if e != nil || !found {
+			return nil, e
+		}
+		if from, err = ParseSalesforceTime(value); err != nil {
+			return
+		}
+	}
+	if to.IsZero() {
+		value, found, e := f.sampleEdge(ctx, opts, "CreatedDate", Desc)
+		if e != nil || !found {
+			return nil, e
+		}
+		if to, err = ParseSalesforceTime(value); err != nil {
+			return
+		}
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("Invalid CreatedDate range %v - %v", from, to)
+	}
+
+	from = from.UTC().Truncate(time.Second)
+	step := to.Sub(from) / time.Duration(opts.Chunks)
+	if step < time.Second {
+		return []string{from.Format(time.RFC3339), ""}, nil
+	}
+	step = step.Truncate(time.Second)
+	for i := 0; i < opts.Chunks; i++ {
+		bounds = append(bounds, from.Add(time.Duration(i)*step).Format(time.RFC3339))
+	}
+	bounds = append(bounds, "")
+
+	return
+}
+
+func decodeBase62(s string) (*big.Int, error) {
+	n := new(big.Int)
+	base := big.NewInt(62)
+	for _, r := range s {
+		i := strings.IndexRune(base62Alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid Salesforce Id %q", s)
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	return n, nil
+}
+
+func encodeBase62(n *big.Int, width int) string {
+	digits := make([]byte, width)
+	value := new(big.Int).Set(n)
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	for i := width - 1; i >= 0; i-- {
+		value.DivMod(value, base, mod)
+		digits[i] = base62Alphabet[mod.Int64()]
+	}
+	return string(digits)
+}
+
+func NewFileProgressStore(dir string) (*FileProgressStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileProgressStore{Dir: dir}, nil
+}
+
+func (s *FileProgressStore) path(jobID string) string {
+	return filepath.Join(s.Dir, jobID+".json")
+}
+
+func (s *FileProgressStore) Load(ctx context.Context, jobID string) (chunks []ExtractChunk, err error) {
+	body, err := ioutil.ReadFile(s.path(jobID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &chunks)
+	return
+}
+
+func (s *FileProgressStore) Save(ctx context.Context, jobID string, chunks []ExtractChunk) error {
+	body, err := json.Marshal(chunks)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(jobID) + ".tmp"
+	if err = ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(jobID))
+}
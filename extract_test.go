@@ -0,0 +1,94 @@
+package gforce
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memoryProgressStore struct {
+	mu     sync.Mutex
+	chunks map[string][]ExtractChunk
+}
+
+func (s *memoryProgressStore) Load(ctx context.Context, jobID string) ([]ExtractChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ExtractChunk(nil), s.chunks[jobID]...), nil
+}
+
+func (s *memoryProgressStore) Save(ctx context.Context, jobID string, chunks []ExtractChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[jobID] = chunks
+	return nil
+}
+
+func TestParallelExtractResume(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != "" {
+			mu.Lock()
+			queries = append(queries, q)
+			mu.Unlock()
+		}
+		serveIteratorPages(w, r)
+	})
+
+	store := &memoryProgressStore{chunks: map[string][]ExtractChunk{
+		"job": {
+			{Index: 0, Lower: "001000000000000", Upper: "001000000000100", Records: 7, Done: true},
+			{Index: 1, Lower: "001000000000100", Upper: "001000000000200", Records: 4,
+				ResumeURL: f.Credentials.InstanceUrl + "/services/data/" + apiVersion + "/query/01g-4"},
+			{Index: 2, Lower: "001000000000200"},
+		},
+	}}
+
+	var out bytes.Buffer
+	chunks, err := f.ParallelExtract(context.Background(), ExtractOptions{
+		SObject:  "Account",
+		Fields:   []string{"Id"},
+		Workers:  2,
+		Progress: store,
+		JobID:    "job",
+	}, NewWriterSink(&out))
+	if err != nil {
+		t.Fatalf("ParallelExtract error '%v'", err)
+	}
+
+	expectedQueries := []string{"SELECT Id FROM Account WHERE Id >= '001000000000200'"}
+	if !reflect.DeepEqual(queries, expectedQueries) {
+		t.Errorf("queries '%v', expected '%v'", queries, expectedQueries)
+	}
+
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		ids = append(ids, decodeTestRecord(t, line)["Id"].(string))
+	}
+	sort.Strings(ids)
+	if expected := []string{"1", "2", "3", "4", "5", "5"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("written ids '%v', expected '%v'", ids, expected)
+	}
+
+	type chunkItem struct {
+		records int
+		done    bool
+	}
+	expectedChunks := []chunkItem{{7, true}, {5, true}, {5, true}}
+	for i, chunk := range chunks {
+		if chunk.Records != expectedChunks[i].records || chunk.Done != expectedChunks[i].done || chunk.ResumeURL != "" {
+			t.Errorf("chunk %d '%+v', expected '%+v'", i, chunk, expectedChunks[i])
+		}
+	}
+	if saved := store.chunks["job"]; !reflect.DeepEqual(saved, chunks) {
+		t.Errorf("saved progress '%+v', expected '%+v'", saved, chunks)
+	}
+}
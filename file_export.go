@@ -0,0 +1,408 @@
+package gforce
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	defaultFileExportWorkers = 4
+	defaultFileExportRetries = 3
+	fileExportIdChunk        = 200
+	fileExportManifest       = "manifest.csv"
+)
+
+var ChecksumMismatchError = errors.New("Checksum mismatch")
+
+// FileExportOptions configures ExportFiles
+type FileExportOptions struct {
+	// RecordIds are the records whose files are exported
+	RecordIds []string
+	// SkipContentVersions and SkipAttachments leave out one kind of file
+	SkipContentVersions bool
+	SkipAttachments     bool
+	// Dir receives the files as <RecordId>/<FileId>_<name> and manifest.csv. Completed files are
+	// skipped and partial ones resumed by the next run.
+	Dir string
+	// Archive, when set, receives a zip of the exported files and the manifest once they are
+	// all downloaded, Dir then only holds the downloads
+	Archive io.Writer
+	// Workers is the number of concurrent downloads, 4 by default
+	Workers int
+	// Retries is the number of attempts of a download, resumed where the last one stopped, 3 by default
+	Retries int
+}
+
+// ExportedFile is a row of the manifest. A file shared by several records is downloaded once,
+// each record has its row with the same Path.
+type ExportedFile struct {
+	RecordId          string
+	SObject           string
+	Id                string
+	ContentDocumentId string
+	Name              string
+	Path              string
+	Size              int64
+	Checksum          string
+}
+
+type exportContentLink struct {
+	LinkedEntityId    string
+	ContentDocumentId string
+}
+
+type exportContentVersion struct {
+	Id                string
+	ContentDocumentId string
+	Title             string
+	PathOnClient      string
+	ContentSize       int64
+	Checksum          string
+}
+
+type exportAttachment struct {
+	Id         string
+	ParentId   string
+	Name       string
+	BodyLength int64
+}
+
+// ExportFiles downloads the latest ContentVersion of the files linked to opts.RecordIds and
+// their Attachments into opts.Dir, concurrently. Interrupted downloads resume with an HTTP Range
+// request, ContentVersions are checked against their MD5 checksum and Attachments against their
+// length. files and the manifest list what was exported, err combines the files that failed;
+// the archive is only written when every file was exported.
+func (f *Force) ExportFiles(ctx context.Context, opts FileExportOptions) (files []ExportedFile, err error) {
+	if opts.Dir == "" {
+		return nil, errors.New("FileExportOptions.Dir is required")
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultFileExportWorkers
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = defaultFileExportRetries
+	}
+
+	if !opts.SkipContentVersions {
+		var versions []ExportedFile
+		if versions, err = f.exportedContentVersions(opts.RecordIds); err != nil {
+			return
+		}
+		files = append(files, versions...)
+	}
+	if !opts.SkipAttachments {
+		var attachments []ExportedFile
+		if attachments, err = f.exportedAttachments(opts.RecordIds); err != nil {
+			return
+		}
+		files = append(files, attachments...)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].RecordId < files[j].RecordId })
+
+	// one download per file, in the folder of the first record it belongs to
+	paths := map[string]string{}
+	var downloads []ExportedFile
+	for i := range files {
+		path, ok := paths[files[i].Id]
+		if !ok {
+			path = filepath.Join(files[i].RecordId, files[i].Id+"_"+safeFileName(files[i].Name))
+			paths[files[i].Id] = path
+			downloads = append(downloads, files[i])
+			downloads[len(downloads)-1].Path = path
+		}
+		files[i].Path = path
+	}
+
+	downloaded := make(map[string]bool, len(downloads))
+	var mu sync.Mutex
+	err = runChunks(ctx, len(downloads), 1, opts.Workers, func(start, end int) error {
+		file := downloads[start]
+		if e := f.downloadFile(ctx, file, filepath.Join(opts.Dir, file.Path), opts.Retries); e != nil {
+			return fmt.Errorf("%s %s: %w", file.SObject, file.Id, e)
+		}
+		mu.Lock()
+		downloaded[file.Id] = true
+		mu.Unlock()
+		return nil
+	})
+
+	// the manifest lists the files downloaded so far, the failed ones are left out
+	exported := files[:0]
+	for _, file := range files {
+		if downloaded[file.Id] {
+			exported = append(exported, file)
+		}
+	}
+	files = exported
+
+	if e := createFileManifest(filepath.Join(opts.Dir, fileExportManifest), files); e != nil {
+		return files, multierr.Append(err, e)
+	}
+	if err == nil && opts.Archive != nil {
+		err = archiveFiles(opts.Archive, opts.Dir, files)
+	}
+	return
+}
+
+func createFileManifest(path string, files []ExportedFile) error {
+	manifest, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = writeFileManifest(manifest, files); err != nil {
+		manifest.Close()
+		return err
+	}
+	return manifest.Close()
+}
+
+func (f *Force) exportedContentVersions(recordIds []string) (files []ExportedFile, err error) {
+	documentRecords := map[string][]string{}
+	var documentIds []string
+	for start := 0; start < len(recordIds); start += fileExportIdChunk {
+		q := NewSOQLQuery("ContentDocumentLink").Where(In("LinkedEntityId", recordIds[start:minInt(start+fileExportIdChunk, len(recordIds))]))
+		links, err := SelectInto[exportContentLink](f, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			if _, ok := documentRecords[link.ContentDocumentId]; !ok {
+				documentIds = append(documentIds, link.ContentDocumentId)
+			}
+			documentRecords[link.ContentDocumentId] = append(documentRecords[link.ContentDocumentId], link.LinkedEntityId)
+		}
+	}
+
+	for start := 0; start < len(documentIds); start += fileExportIdChunk {
+		q := NewSOQLQuery("ContentVersion").Where(
+			In("ContentDocumentId", documentIds[start:minInt(start+fileExportIdChunk, len(documentIds))]),
+			Eq("IsLatest", true),
+		)
+		versions, err := SelectInto[exportContentVersion](f, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			// PathOnClient may be a Windows path
+			name := version.PathOnClient[strings.LastIndexAny(version.PathOnClient, `/\`)+1:]
+			if name == "" {
+				name = version.Title
+			}
+			for _, recordId := range documentRecords[version.ContentDocumentId] {
+				files = append(files, ExportedFile{
+					RecordId:          recordId,
+					SObject:           "ContentVersion",
+					Id:                version.Id,
+					ContentDocumentId: version.ContentDocumentId,
+					Name:              name,
+					Size:              version.ContentSize,
+					Checksum:          version.Checksum,
+				})
+			}
+		}
+	}
+	return
+}
+
+func (f *Force) exportedAttachments(recordIds []string) (files []ExportedFile, err error) {
+	for start := 0; start < len(recordIds); start += fileExportIdChunk {
+		q := NewSOQLQuery("Attachment").Where(In("ParentId", recordIds[start:minInt(start+fileExportIdChunk, len(recordIds))]))
+		attachments, err := SelectInto[exportAttachment](f, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, attachment := range attachments {
+			files = append(files, ExportedFile{
+				RecordId: attachment.ParentId,
+				SObject:  "Attachment",
+				Id:       attachment.Id,
+				Name:     attachment.Name,
+				Size:     attachment.BodyLength,
+			})
+		}
+	}
+	return
+}
+
+// downloadFile writes the blob of file to path through path.part, resuming the part left by an
+// earlier attempt or run. An existing path that passes verification is kept.
+func (f *Force) downloadFile(ctx context.Context, file ExportedFile, path string, retries int) (err error) {
+	if verifyFile(path, file) == nil {
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	part := path + ".part"
+	field := "VersionData"
+	if file.SObject == "Attachment" {
+		field = "Body"
+	}
+
+	refreshed := false
+	for attempt := 0; attempt < retries; attempt++ {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		err = f.downloadPart(ctx, file.SObject, file.Id, field, part)
+		if err == SessionExpiredError && !refreshed && f.Credentials.RefreshToken != "" {
+			if err = f.RefreshSession(); err != nil {
+				return
+			}
+			refreshed = true
+			attempt--
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if err = verifyFile(part, file); err != nil {
+			// a corrupted part is not resumed
+			os.Remove(part)
+			continue
+		}
+		return os.Rename(part, path)
+	}
+	return
+}
+
+// downloadPart appends the rest of the blob to part, or rewrites it when the range is ignored
+func (f *Force) downloadPart(ctx context.Context, sobject, id, field, part string) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	res, err := f.GetBase64StreamRange(ctx, sobject, id, field, offset)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the part is already complete
+		return nil
+	case http.StatusUnauthorized:
+		return SessionExpiredError
+	default:
+		body, _ := ioutil.ReadAll(res.Body)
+		return newSubrequestError("", res.StatusCode, body)
+	}
+
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, res.Body); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// verifyFile checks the length of path and, when file has one, its MD5 checksum
+func verifyFile(path string, file ExportedFile) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	hash := md5.New()
+	size, err := io.Copy(hash, in)
+	if err != nil {
+		return err
+	}
+	if size != file.Size {
+		return fmt.Errorf("%w: %d bytes, expected %d", ChecksumMismatchError, size, file.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); file.Checksum != "" && !strings.EqualFold(sum, file.Checksum) {
+		return fmt.Errorf("%w: md5 %s, expected %s", ChecksumMismatchError, sum, file.Checksum)
+	}
+	return nil
+}
+
+func writeFileManifest(w io.Writer, files []ExportedFile) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"RecordId", "SObject", "Id", "ContentDocumentId", "Name", "Path", "Size", "Checksum"})
+	for _, file := range files {
+		writer.Write([]string{file.RecordId, file.SObject, file.Id, file.ContentDocumentId, file.Name,
+			filepath.ToSlash(file.Path), fmt.Sprint(file.Size), file.Checksum})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// archiveFiles zips the downloads of dir and the manifest, each file once
+func archiveFiles(w io.Writer, dir string, files []ExportedFile) error {
+	archive := zip.NewWriter(w)
+	added := map[string]bool{}
+	for _, name := range append([]string{fileExportManifest}, exportedPaths(files)...) {
+		if added[name] {
+			continue
+		}
+		added[name] = true
+		entry, err := archive.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(entry, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func exportedPaths(files []ExportedFile) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+// safeFileName replaces the characters that are not allowed in file names
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,50 @@
+package gforce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportFilesPartialManifest(t *testing.T) {
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/query"):
+			fmt.Fprint(w, `{"done":true,"totalSize":2,"records":[`+
+				`{"Id":"00PA","ParentId":"001A","Name":"a.txt","BodyLength":5},`+
+				`{"Id":"00PB","ParentId":"001A","Name":"b.txt","BodyLength":5}]}`)
+		case strings.HasSuffix(r.URL.Path, "/Attachment/00PA/Body"):
+			fmt.Fprint(w, "hello")
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `[{"message":"unavailable","errorCode":"SERVER_UNAVAILABLE"}]`)
+		}
+	})
+	dir := t.TempDir()
+	files, err := f.ExportFiles(context.Background(), FileExportOptions{
+		RecordIds:           []string{"001A"},
+		SkipContentVersions: true,
+		Dir:                 dir,
+		Retries:             1,
+	})
+	if err == nil || !strings.Contains(err.Error(), "00PB") {
+		t.Errorf("expected error for the failed download '%v'", err)
+	}
+	if len(files) != 1 || files[0].Id != "00PA" {
+		t.Errorf("invalid exported files '%v'", files)
+	}
+	manifest, e := os.ReadFile(filepath.Join(dir, fileExportManifest))
+	if e != nil {
+		t.Fatalf("read manifest: %v", e)
+	}
+	if !strings.Contains(string(manifest), "00PA") || strings.Contains(string(manifest), "00PB") {
+		t.Errorf("invalid manifest '%s'", manifest)
+	}
+	if body, e := os.ReadFile(filepath.Join(dir, files[0].Path)); e != nil || string(body) != "hello" {
+		t.Errorf("invalid exported file '%s' '%v'", body, e)
+	}
+}
@@ -0,0 +1,425 @@
+package gforce
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlattenOptions controls how FlattenRecord turns nested records into rows
+type FlattenOptions struct {
+	// Explode names a child relationship (e.g. Contacts) to emit one row per child record,
+	// with the child fields prefixed by the relationship name. A parent without children
+	// still produces a single row. Other child relationships are kept as JSON text.
+	Explode string
+}
+
+// CSVDialect describes the CSV written by CSVSink
+type CSVDialect struct {
+	Delimiter rune
+	CRLF      bool
+	// QuoteAll quotes every value instead of only the values that need it
+	QuoteAll bool
+	// Null is written for missing and null values
+	Null string
+	// NoHeader omits the header line
+	NoHeader bool
+}
+
+// DefaultCSVDialect is RFC 4180 with LF line endings
+var DefaultCSVDialect = CSVDialect{Delimiter: ','}
+
+// BulkCSVDialect matches the CSV produced by the Bulk API: comma delimited, LF line endings,
+// every value quoted and null written as an empty value
+var BulkCSVDialect = CSVDialect{Delimiter: ',', QuoteAll: true}
+
+// FlattenRecord turns a record into rows keyed by dotted column names (Account.Owner.Name),
+// dropping the attributes of the record and of its parents
+func FlattenRecord(record ForceRecord, options FlattenOptions) (rows []map[string]interface{}) {
+	base := map[string]interface{}{}
+	var children []map[string]interface{}
+	flattenInto(base, "", record, options.Explode, &children)
+
+	if options.Explode == "" || len(children) == 0 {
+		return []map[string]interface{}{base}
+	}
+
+	for _, child := range children {
+		row := make(map[string]interface{}, len(base)+len(child))
+		for k, v := range base {
+			row[k] = v
+		}
+		flattenInto(row, options.Explode+".", child, "", nil)
+		rows = append(rows, row)
+	}
+	return
+}
+
+func flattenInto(row map[string]interface{}, prefix string, record map[string]interface{}, explode string, children *[]map[string]interface{}) {
+	for key, value := range record {
+		if key == "attributes" {
+			continue
+		}
+		name := prefix + key
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if _, isChild := v["records"]; isChild {
+				if prefix == "" && explode != "" && strings.EqualFold(key, explode) {
+					*children, _ = childRecords(v)
+					continue
+				}
+				row[name] = childJSON(v)
+				continue
+			}
+			flattenInto(row, name+".", v, "", nil)
+		case ForceRecord:
+			flattenInto(row, name+".", v, "", nil)
+		default:
+			row[name] = value
+		}
+	}
+}
+
+// childJSON strips a subquery result down to its records for the JSON text column
+func childJSON(result map[string]interface{}) string {
+	rows, _ := childRecords(result)
+	flat := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		flat = append(flat, FlattenRecord(row, FlattenOptions{})...)
+	}
+	b, _ := json.Marshal(flat)
+	return string(b)
+}
+
+// Columns returns the flattened column names of q in SELECT order. Subqueries appear as one
+// column named after the relationship, or as <relationship>.<field> columns when exploded.
+func (q *SOQLQuery) Columns(options FlattenOptions) (columns []string) {
+	columns = append(columns, q.fields...)
+	for _, child := range q.subqueries {
+		if options.Explode != "" && strings.EqualFold(child.sobject, options.Explode) {
+			for _, field := range child.fields {
+				columns = append(columns, child.sobject+"."+field)
+			}
+			continue
+		}
+		columns = append(columns, child.sobject)
+	}
+	return
+}
+
+// SOQLColumns returns the flattened column names of a SOQL query string in SELECT order.
+// Aggregates without alias are named expr0, expr1... as in the API response.
+func SOQLColumns(query string, options FlattenOptions) (columns []string, err error) {
+	trimmed := strings.TrimSpace(query)
+	if len(trimmed) < 7 || !strings.EqualFold(trimmed[:7], "SELECT ") {
+		return nil, fmt.Errorf("Not a SELECT query: %s", query)
+	}
+
+	items, rest := splitSelectList(trimmed[7:])
+	if !strings.HasPrefix(strings.ToUpper(rest), "FROM ") {
+		return nil, fmt.Errorf("FROM clause not found: %s", query)
+	}
+
+	expr := 0
+	for i, item := range items {
+		if item == "" {
+			return nil, fmt.Errorf("Empty select item %d: %s", i+1, query)
+		}
+		if strings.HasPrefix(item, "(") {
+			sub := strings.TrimSuffix(strings.TrimPrefix(item, "("), ")")
+			subColumns, e := SOQLColumns(sub, FlattenOptions{})
+			if e != nil {
+				return nil, e
+			}
+			relationship := soqlFromObject(sub)
+			if options.Explode != "" && strings.EqualFold(relationship, options.Explode) {
+				for _, c := range subColumns {
+					columns = append(columns, relationship+"."+c)
+				}
+			} else {
+				columns = append(columns, relationship)
+			}
+			continue
+		}
+
+		words := strings.Fields(item)
+		open := strings.Index(item, "(")
+		switch {
+		case open >= 0 && strings.LastIndex(item, ")") < open:
+			return nil, fmt.Errorf("Unbalanced parenthesis in select item %s: %s", item, query)
+		case open < 0:
+			columns = append(columns, words[0])
+		case len(words) > 1 && !strings.HasSuffix(words[len(words)-1], ")"):
+			// aliased expression
+			columns = append(columns, words[len(words)-1])
+		case isFieldFunction(item[:open]):
+			columns = append(columns, strings.TrimSpace(item[open+1:strings.LastIndex(item, ")")]))
+		default:
+			columns = append(columns, "expr"+strconv.Itoa(expr))
+			expr++
+		}
+	}
+	return
+}
+
+// splitSelectList splits the SELECT list on top level commas and returns what follows it
+func splitSelectList(s string) (items []string, rest string) {
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 && i+6 <= len(s) && strings.EqualFold(s[i+1:i+6], "FROM ") {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				return items, strings.TrimSpace(s[i+1:])
+			}
+		}
+	}
+	return append(items, strings.TrimSpace(s[start:])), ""
+}
+
+func soqlFromObject(query string) string {
+	_, rest := splitSelectList(strings.TrimSpace(query)[7:])
+	words := strings.Fields(rest)
+	if len(words) < 2 {
+		return ""
+	}
+	return words[1]
+}
+
+func isFieldFunction(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "tolabel", "format", "convertcurrency":
+		return true
+	}
+	return false
+}
+
+// flatColumns resolves columns against a row case insensitively, since SOQL field names need
+// not match the casing of the API names returned in records
+type flatColumns struct {
+	names []string
+	fixed bool
+}
+
+func (c *flatColumns) values(row map[string]interface{}) []interface{} {
+	if !c.fixed {
+		for name := range row {
+			c.names = append(c.names, name)
+		}
+		sort.Strings(c.names)
+		c.fixed = true
+	}
+	lower := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		lower[strings.ToLower(k)] = v
+	}
+	values := make([]interface{}, len(c.names))
+	for i, name := range c.names {
+		if v, ok := row[name]; ok {
+			values[i] = v
+		} else {
+			values[i] = lower[strings.ToLower(name)]
+		}
+	}
+	return values
+}
+
+// formatFlatValue renders a flattened value as text, ok is false for null
+func formatFlatValue(value interface{}) (text string, ok bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return v.String(), true
+	case time.Time:
+		return v.UTC().Format("2006-01-02T15:04:05.000Z"), true
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v), true
+		}
+		return string(b), true
+	}
+}
+
+// CSVSink writes flattened records as CSV. Without columns, the sorted columns of the first
+// row are used; columns missing from the first page are then dropped.
+type CSVSink struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	dialect CSVDialect
+	options FlattenOptions
+	columns flatColumns
+	header  bool
+	closed  bool
+}
+
+func NewCSVSink(w io.Writer, columns []string, dialect CSVDialect, options FlattenOptions) *CSVSink {
+	if dialect.Delimiter == 0 {
+		dialect.Delimiter = ','
+	}
+	return &CSVSink{
+		w:       bufio.NewWriter(w),
+		dialect: dialect,
+		options: options,
+		columns: flatColumns{names: columns, fixed: len(columns) > 0},
+	}
+}
+
+func (s *CSVSink) WritePage(ctx context.Context, page QueryPage) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", SinkClosedError
+	}
+	for _, record := range page.Records {
+		for _, row := range FlattenRecord(record, s.options) {
+			values := s.columns.values(row)
+			if !s.header {
+				s.header = true
+				if !s.dialect.NoHeader {
+					header := make([]interface{}, len(s.columns.names))
+					for i, name := range s.columns.names {
+						header[i] = name
+					}
+					if err := s.writeLine(header); err != nil {
+						return "", err
+					}
+				}
+			}
+			if err := s.writeLine(values); err != nil {
+				return "", err
+			}
+		}
+	}
+	return "", s.w.Flush()
+}
+
+func (s *CSVSink) writeLine(values []interface{}) error {
+	for i, value := range values {
+		if i > 0 {
+			s.w.WriteRune(s.dialect.Delimiter)
+		}
+		text, ok := formatFlatValue(value)
+		if !ok {
+			text = s.dialect.Null
+		}
+		if s.dialect.QuoteAll || strings.ContainsAny(text, string(s.dialect.Delimiter)+"\"\r\n") ||
+			(text != "" && (text[0] == ' ' || text[len(text)-1] == ' ')) {
+			text = `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		}
+		s.w.WriteString(text)
+	}
+	if s.dialect.CRLF {
+		s.w.WriteString("\r\n")
+	} else {
+		s.w.WriteString("\n")
+	}
+	return nil
+}
+
+func (s *CSVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.w.Flush()
+}
+
+// FlatNDJSONSink writes flattened records as newline delimited JSON objects whose keys follow
+// the column order. Without columns, the sorted columns of the first row are used.
+type FlatNDJSONSink struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	options FlattenOptions
+	columns flatColumns
+	closed  bool
+}
+
+func NewFlatNDJSONSink(w io.Writer, columns []string, options FlattenOptions) *FlatNDJSONSink {
+	return &FlatNDJSONSink{
+		w:       bufio.NewWriter(w),
+		options: options,
+		columns: flatColumns{names: columns, fixed: len(columns) > 0},
+	}
+}
+
+func (s *FlatNDJSONSink) WritePage(ctx context.Context, page QueryPage) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", SinkClosedError
+	}
+	for _, record := range page.Records {
+		for _, row := range FlattenRecord(record, s.options) {
+			values := s.columns.values(row)
+			s.w.WriteByte('{')
+			for i, name := range s.columns.names {
+				if i > 0 {
+					s.w.WriteByte(',')
+				}
+				key, _ := json.Marshal(name)
+				value, err := json.Marshal(values[i])
+				if err != nil {
+					return "", err
+				}
+				s.w.Write(key)
+				s.w.WriteByte(':')
+				s.w.Write(value)
+			}
+			s.w.WriteString("}\n")
+		}
+	}
+	return "", s.w.Flush()
+}
+
+func (s *FlatNDJSONSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.w.Flush()
+}
+
+// StreamIterator sends the remaining pages of it to sink and closes both
+func (f *Force) StreamIterator(ctx context.Context, it *QueryIterator, sink QuerySink) (pages []QueryPageInfo, err error) {
+	return f.streamPages(ctx, it, sink)
+}
+
+// ExportCSV streams query to w as CSV with the columns of its SELECT list
+func (f *Force) ExportCSV(ctx context.Context, query string, w io.Writer, dialect CSVDialect, flatten FlattenOptions, options ...func(*QueryOptions)) (pages []QueryPageInfo, err error) {
+	columns, err := SOQLColumns(query, flatten)
+	if err != nil {
+		return
+	}
+	return f.QueryStream(ctx, query, NewCSVSink(w, columns, dialect, flatten), options...)
+}
+
+// ExportNDJSON streams query to w as flattened NDJSON with the columns of its SELECT list
+func (f *Force) ExportNDJSON(ctx context.Context, query string, w io.Writer, flatten FlattenOptions, options ...func(*QueryOptions)) (pages []QueryPageInfo, err error) {
+	columns, err := SOQLColumns(query, flatten)
+	if err != nil {
+		return
+	}
+	return f.QueryStream(ctx, query, NewFlatNDJSONSink(w, columns, flatten), options...)
+}
@@ -0,0 +1,108 @@
+package gforce
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenRecord(t *testing.T) {
+	type flattenItem struct {
+		record  string
+		options FlattenOptions
+		rows    []map[string]interface{}
+	}
+	table := []flattenItem{
+		{
+			record: `{"attributes":{"type":"Contact"},"Id":"003A","Account":{"attributes":{"type":"Account"},"Name":"Acme","Owner":{"Name":"Ana"}}}`,
+			rows:   []map[string]interface{}{{"Id": "003A", "Account.Name": "Acme", "Account.Owner.Name": "Ana"}},
+		},
+		{
+			record: `{"Id":"001A","Contacts":{"totalSize":2,"done":true,"records":[{"attributes":{"type":"Contact"},"Id":"003A"},{"Id":"003B"}]}}`,
+			rows:   []map[string]interface{}{{"Id": "001A", "Contacts": `[{"Id":"003A"},{"Id":"003B"}]`}},
+		},
+		{
+			record:  `{"Id":"001A","Contacts":{"totalSize":2,"done":true,"records":[{"Id":"003A","Owner":{"Name":"Ana"}},{"Id":"003B","Owner":null}]}}`,
+			options: FlattenOptions{Explode: "Contacts"},
+			rows: []map[string]interface{}{
+				{"Id": "001A", "Contacts.Id": "003A", "Contacts.Owner.Name": "Ana"},
+				{"Id": "001A", "Contacts.Id": "003B", "Contacts.Owner": nil},
+			},
+		},
+		{
+			record:  `{"Id":"001A","Contacts":null}`,
+			options: FlattenOptions{Explode: "Contacts"},
+			rows:    []map[string]interface{}{{"Id": "001A", "Contacts": nil}},
+		},
+	}
+	for _, tt := range table {
+		rows := FlattenRecord(decodeTestRecord(t, tt.record), tt.options)
+		if !reflect.DeepEqual(rows, tt.rows) {
+			t.Errorf("flatten '%v': '%v', expected '%v'", tt.record, rows, tt.rows)
+		}
+	}
+}
+
+func TestSOQLColumns(t *testing.T) {
+	type columnsItem struct {
+		query   string
+		options FlattenOptions
+		columns []string
+		err     bool
+	}
+	table := []columnsItem{
+		{
+			query:   "SELECT Id, Account.Owner.Name FROM Contact",
+			columns: []string{"Id", "Account.Owner.Name"},
+		},
+		{
+			query:   "select StageName, COUNT(Id), SUM(Amount) total, MAX(CloseDate) from Opportunity GROUP BY StageName",
+			columns: []string{"StageName", "expr0", "total", "expr1"},
+		},
+		{
+			query:   "SELECT Id, toLabel(Status), FORMAT(Amount) FROM Opportunity",
+			columns: []string{"Id", "Status", "Amount"},
+		},
+		{
+			query:   "SELECT Id, (SELECT Id, Name FROM Contacts) FROM Account",
+			columns: []string{"Id", "Contacts"},
+		},
+		{
+			query:   "SELECT Id, (SELECT Id, Name FROM Contacts) FROM Account",
+			options: FlattenOptions{Explode: "Contacts"},
+			columns: []string{"Id", "Contacts.Id", "Contacts.Name"},
+		},
+		{
+			query: "UPDATE Account",
+			err:   true,
+		},
+		{
+			query: "SELECT Id",
+			err:   true,
+		},
+		{
+			query: "SELECT Id, FROM Account",
+			err:   true,
+		},
+		{
+			query: "SELECT Id,, Name FROM Account",
+			err:   true,
+		},
+		{
+			query: "SELECT Id, (SELECT Id, FROM Contacts) FROM Account",
+			err:   true,
+		},
+		{
+			query: "SELECT Id, Status)x( FROM Case",
+			err:   true,
+		},
+	}
+	for _, tt := range table {
+		columns, err := SOQLColumns(tt.query, tt.options)
+		if (err != nil) != tt.err {
+			t.Errorf("columns of '%v' error '%v'", tt.query, err)
+		}
+		if !reflect.DeepEqual(columns, tt.columns) {
+			t.Errorf("columns of '%v': '%v', expected '%v'", tt.query, columns, tt.columns)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package gforce
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -112,9 +113,9 @@ type GenericForceError struct {
 }
 
 type ForceError struct {
-	Message   string   `json:"message"`
-	ErrorCode string   `json:"errorCode"`
-	Fields    []string `json:"fields"`
+	Message   string   `json:"message" xml:"message"`
+	ErrorCode string   `json:"errorCode" xml:"statusCode"`
+	Fields    []string `json:"fields" xml:"fields"`
 }
 
 type FieldName struct {
@@ -202,6 +203,7 @@ type Result struct {
 type QueryOptions struct {
 	IsTooling bool
 	QueryAll  bool
+	Prefetch  bool
 }
 
 type AuraDefinitionBundleResult struct {
@@ -575,85 +577,51 @@ func (f *Force) ListViewDescribeSObject(name, id string) (result string, err err
 	return
 }
 
+// QueryAndSend sends every record of query to processor and closes it when the query ends,
+// also when it fails.
 func (f *Force) QueryAndSend(query string, processor chan<- ForceRecord, options ...func(*QueryOptions)) (err error) {
-	queryOptions := QueryOptions{}
-	for _, option := range options {
-		option(&queryOptions)
-	}
-	cmd := "query"
-	if queryOptions.QueryAll {
-		cmd = "queryAll"
-	}
-	if queryOptions.IsTooling {
-		cmd = "tooling/" + cmd
-	}
-	processResults := func(body []byte) (result ForceQueryResult, err error) {
-		err = json.Unmarshal(body, &result)
-		if err != nil {
-			return
-		}
-		for _, row := range result.Records {
-			processor <- row
-		}
-		return
-	}
+	defer close(processor)
 
-	var body []byte
-	url := fmt.Sprintf("%s/services/data/%s/%s?q=%s", f.Credentials.InstanceUrl, apiVersion, cmd, url.QueryEscape(query))
-	for {
-		body, err = f.httpGet(url, false)
-		if err != nil {
-			return
-		}
-		var result ForceQueryResult
-		result, err = processResults(body)
-		if err != nil {
-			return
-		}
-		if result.Done {
-			break
-		}
-		url = fmt.Sprintf("%s%s", f.Credentials.InstanceUrl, result.NextRecordsUrl)
+	it := f.NewQueryIterator(context.Background(), query, options...)
+	defer it.Close()
+
+	for it.Next() {
+		processor <- it.Record()
 	}
-	close(processor)
-	return
+
+	return it.Err()
 }
 
 func (f *Force) Query(query string, queryAll, tooling bool) (result ForceQueryResult, err error) {
-	// queryOptions := QueryOptions{}
-	// for _, option := range options {
-	// 	option(&queryOptions)
-	// }
-	cmd := "query"
+	options := []func(*QueryOptions){}
 	if queryAll {
-		cmd = "queryAll"
+		options = append(options, WithQueryAll)
 	}
 	if tooling {
-		cmd = "tooling/" + cmd
+		options = append(options, WithTooling)
 	}
 
-	result = ForceQueryResult{
-		Done:           false,
-		NextRecordsUrl: fmt.Sprintf("%s/services/data/%s/%s?q=%s", f.Credentials.InstanceUrl, apiVersion, cmd, url.QueryEscape(query)),
-		TotalSize:      0,
-		Records:        []ForceRecord{},
-	}
+	return f.queryAllPages(f.NewQueryIterator(context.Background(), query, options...))
+}
 
-	/* The Force API will split queries returning large result sets into
-	 * multiple pieces (generally every 200 records). We need to repeatedly
-	 * query until we've retrieved all of them. */
-	for !result.Done {
-		var body []byte
-		body, err = f.httpGet(result.NextRecordsUrl, false)
+// queryAllPages drains it into a single result
+func (f *Force) queryAllPages(it *QueryIterator) (result ForceQueryResult, err error) {
+	defer it.Close()
 
-		if err != nil {
-			return
+	result.Records = []ForceRecord{}
+	for {
+		records, ok := it.Page()
+		if !ok {
+			break
 		}
-
-		var currResult ForceQueryResult
-		json.Unmarshal(body, &currResult)
-		result.Update(currResult, f)
+		result.Records = append(result.Records, records...)
 	}
+	if err = it.Err(); err != nil {
+		return
+	}
+
+	result.Done = true
+	result.TotalSize = it.TotalSize()
 
 	return
 }
@@ -1042,6 +1010,22 @@ func (f *Force) GetBase64Stream(sobject, id, field string) (response *http.Respo
 	return
 }
 
+// GetBase64StreamRange streams a blob field from offset. The response is 206 when the range is
+// honored, 200 with the whole blob otherwise.
+func (f *Force) GetBase64StreamRange(ctx context.Context, sobject, id, field string, offset int64) (response *http.Response, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/sobjects/%s/%s/%s", f.Credentials.InstanceUrl, apiVersion, sobject, id, field)
+	req, err := httpRequest("GET", url, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
+	if offset > 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return doRequest(req)
+}
+
 func (f *Force) CreateRecord(sobject string, attrs map[string]string) (id string, err error, emessages []ForceError) {
 	url := fmt.Sprintf("%s/services/data/%s/sobjects/%s", f.Credentials.InstanceUrl, apiVersion, sobject)
 	body, err, emessages := f.httpPost(url, attrs, false)
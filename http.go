@@ -2,6 +2,7 @@ package gforce
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -30,19 +31,23 @@ func (f *Force) SetHTTPTraceDetail(trace bool) {
 // GET
 
 func (f *Force) httpGet(url string, refreshed bool) (body []byte, err error) {
+	return f.httpGetContext(context.Background(), url, refreshed)
+}
+
+func (f *Force) httpGetContext(ctx context.Context, url string, refreshed bool) (body []byte, err error) {
 	headers := map[string]string{
 		"Authorization":  fmt.Sprintf("Bearer %s", f.Credentials.AccessToken),
 		"X-SFDC-Session": fmt.Sprintf("Bearer %s", f.Credentials.AccessToken),
 	}
-	body, err = f.httpGetRequest(url, headers)
+	body, err = f.httpGetRequestContext(ctx, url, headers)
 	if err == SessionExpiredError {
 		if f.Credentials.RefreshToken != "" && !refreshed {
 			log.Printf("Session Expired Credentials: RefreshToken (%v) - refreshed (%v)", f.Credentials.RefreshToken, refreshed)
 			if e := f.RefreshSession(); e != nil {
-				log.Printf("Error f.RefreshSession(): %w", e)
+				log.Printf("Error f.RefreshSession(): %v", e)
 				return nil, e
 			}
-			return f.httpGet(url, true)
+			return f.httpGetContext(ctx, url, true)
 		}
 		return nil, err
 	}
@@ -92,16 +97,21 @@ func (f *Force) httpGetBulkJSON(url string, refreshed bool) (body []byte, err er
 }
 
 func (f *Force) httpGetRequest(url string, headers map[string]string) (body []byte, err error) {
+	return f.httpGetRequestContext(context.Background(), url, headers)
+}
+
+func (f *Force) httpGetRequestContext(ctx context.Context, url string, headers map[string]string) (body []byte, err error) {
 	req, err := httpRequest("GET", url, nil)
 	if err != nil {
 		return
 	}
+	req = req.WithContext(ctx)
 	for headerName, headerValue := range headers {
 		req.Header.Add(headerName, headerValue)
 	}
 	res, err := doRequest(req)
 	if err != nil {
-		log.Printf("Error doRequest httpGetRequest: %w", err)
+		log.Printf("Error doRequest httpGetRequest: %v", err)
 		return
 	}
 	defer res.Body.Close()
@@ -298,15 +308,19 @@ func (f *Force) httpPatchXML(url string, data string, refreshed bool) (body []by
 }
 
 func (f *Force) httpPatchJSON(url string, data string, refreshed bool) (body []byte, err error) {
-	body, err = f.httpPatchWithContentType(url, data, "application/json")
+	return f.httpPatchJSONContext(context.Background(), url, data, refreshed)
+}
+
+func (f *Force) httpPatchJSONContext(ctx context.Context, url string, data string, refreshed bool) (body []byte, err error) {
+	body, err = f.httpPutPatchPostWithContentTypeContext(ctx, url, data, "application/json", "PATCH")
 	if err == SessionExpiredError {
 		if f.Credentials.RefreshToken != "" && !refreshed {
 			log.Printf("Attempt to refresh session: %+v", f.Credentials)
 			if e := f.RefreshSession(); e != nil {
-				log.Printf("Error on RefreshSession: %w", e)
+				log.Printf("Error on RefreshSession: %v", e)
 				return nil, e
 			}
-			return f.httpPatchJSON(url, data, true)
+			return f.httpPatchJSONContext(ctx, url, data, true)
 		}
 		return nil, err
 	}
@@ -391,13 +405,17 @@ func (f *Force) httpPostXML(url string, data string, refreshed bool) (body []byt
 }
 
 func (f *Force) httpPostJSON(url string, data string, refreshed bool) (body []byte, err error) {
-	body, err = f.httpPostWithContentType(url, data, "application/json")
+	return f.httpPostJSONContext(context.Background(), url, data, refreshed)
+}
+
+func (f *Force) httpPostJSONContext(ctx context.Context, url string, data string, refreshed bool) (body []byte, err error) {
+	body, err = f.httpPutPatchPostWithContentTypeContext(ctx, url, data, "application/json", "POST")
 	if err == SessionExpiredError {
 		if f.Credentials.RefreshToken != "" && !refreshed {
 			if e := f.RefreshSession(); e != nil {
 				return nil, e
 			}
-			return f.httpPostJSON(url, data, true)
+			return f.httpPostJSONContext(ctx, url, data, true)
 		}
 		return nil, err
 	}
@@ -474,12 +492,17 @@ func (f *Force) httpPostAttributes(url string, attrs map[string]string) (body []
 // PUT/PATCH/POST
 
 func (f *Force) httpPutPatchPostWithContentType(url string, data string, contenttype string, method string) (body []byte, err error) {
+	return f.httpPutPatchPostWithContentTypeContext(context.Background(), url, data, contenttype, method)
+}
+
+func (f *Force) httpPutPatchPostWithContentTypeContext(ctx context.Context, url string, data string, contenttype string, method string) (body []byte, err error) {
 	rbody := data
 
 	req, err := httpRequest(strings.ToUpper(method), url, bytes.NewReader([]byte(rbody)))
 	if err != nil {
 		return
 	}
+	req = req.WithContext(ctx)
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
 	req.Header.Add("X-SFDC-Session", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
@@ -536,16 +559,58 @@ func (f *Force) httpPutPatchPostWithContentType(url string, data string, content
 	return
 }
 
+// httpSendJSON sends a JSON request with headers and returns the response whatever its status,
+// for the callers that tell statuses apart. Only an expired session is an error.
+func (f *Force) httpSendJSON(method, url, data string, headers map[string]string, refreshed bool) (res *http.Response, body []byte, err error) {
+	var rbody io.Reader
+	if data != "" {
+		rbody = strings.NewReader(data)
+	}
+	req, err := httpRequest(method, url, rbody)
+	if err != nil {
+		return
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
+	req.Header.Add("X-SFDC-Session", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
+	if data != "" {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	for headerName, headerValue := range headers {
+		req.Header.Add(headerName, headerValue)
+	}
+	res, err = doRequest(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 401 {
+		if f.Credentials.RefreshToken != "" && !refreshed {
+			if e := f.RefreshSession(); e != nil {
+				return nil, nil, e
+			}
+			return f.httpSendJSON(method, url, data, headers, true)
+		}
+		return nil, nil, SessionExpiredError
+	}
+	body, err = ioutil.ReadAll(res.Body)
+	return
+}
+
 // DELETE
 
 func (f *Force) httpDelete(url string, refreshed bool) (body []byte, err error) {
-	body, err = f.httpDeleteUrl(url)
+	return f.httpDeleteContext(context.Background(), url, refreshed)
+}
+
+func (f *Force) httpDeleteContext(ctx context.Context, url string, refreshed bool) (body []byte, err error) {
+	body, err = f.httpDeleteUrlContext(ctx, url)
 	if err == SessionExpiredError {
 		if f.Credentials.RefreshToken != "" && !refreshed {
 			if e := f.RefreshSession(); e != nil {
 				return nil, e
 			}
-			return f.httpDelete(url, true)
+			return f.httpDeleteContext(ctx, url, true)
 		}
 		return nil, err
 	}
@@ -553,10 +618,15 @@ func (f *Force) httpDelete(url string, refreshed bool) (body []byte, err error)
 }
 
 func (f *Force) httpDeleteUrl(url string) (body []byte, err error) {
+	return f.httpDeleteUrlContext(context.Background(), url)
+}
+
+func (f *Force) httpDeleteUrlContext(ctx context.Context, url string) (body []byte, err error) {
 	req, err := httpRequest("DELETE", url, nil)
 	if err != nil {
 		return
 	}
+	req = req.WithContext(ctx)
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
 	req.Header.Add("X-SFDC-Session", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
 	res, err := doRequest(req)
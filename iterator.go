@@ -0,0 +1,241 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+var QueryIteratorClosedError = errors.New("Query iterator closed")
+
+// QueryIterator walks a query result one record at a time, holding a single page in memory.
+//
+//	it := f.NewQueryIterator(ctx, "SELECT Id FROM Account", WithQueryAll, WithPrefetch)
+//	defer it.Close()
+//	for it.Next() {
+//		record := it.Record()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+//
+// When a run has to be interrupted, ResumeURL returns the URL to pass to ResumeQueryIterator so
+// that no unread record is lost.
+type QueryIterator struct {
+	force     *Force
+	ctx       context.Context
+	cancel    context.CancelFunc
+	prefetch  bool
+	pageURL   string
+	nextURL   string
+	records   []ForceRecord
+	index     int
+	record    ForceRecord
+	totalSize int
+	started   bool
+	pending   chan queryPage
+	err       error
+}
+
+type queryPage struct {
+	url    string
+	result ForceQueryResult
+	err    error
+}
+
+func WithQueryAll(options *QueryOptions) {
+	options.QueryAll = true
+}
+
+func WithTooling(options *QueryOptions) {
+	options.IsTooling = true
+}
+
+// WithPrefetch makes a QueryIterator request the next page while the current one is consumed
+func WithPrefetch(options *QueryOptions) {
+	options.Prefetch = true
+}
+
+// NewQueryIterator prepares query for iteration, no request is sent before the first call to Next
+func (f *Force) NewQueryIterator(ctx context.Context, query string, options ...func(*QueryOptions)) *QueryIterator {
+	queryOptions := QueryOptions{}
+	for _, option := range options {
+		option(&queryOptions)
+	}
+	url := fmt.Sprintf("%s%s", f.Credentials.InstanceUrl, queryOptionsPath(query, queryOptions))
+	return newQueryIterator(ctx, f, url, queryOptions)
+}
+
+// ResumeQueryIterator continues an iteration from a URL returned by ResumeURL or from a
+// nextRecordsUrl, either absolute or relative to the instance
+func (f *Force) ResumeQueryIterator(ctx context.Context, nextRecordsURL string, options ...func(*QueryOptions)) *QueryIterator {
+	queryOptions := QueryOptions{}
+	for _, option := range options {
+		option(&queryOptions)
+	}
+	if u, err := url.Parse(nextRecordsURL); err == nil && !u.IsAbs() {
+		nextRecordsURL = fmt.Sprintf("%s%s", f.Credentials.InstanceUrl, nextRecordsURL)
+	}
+	return newQueryIterator(ctx, f, nextRecordsURL, queryOptions)
+}
+
+func newQueryIterator(ctx context.Context, f *Force, url string, options QueryOptions) *QueryIterator {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &QueryIterator{
+		force:    f,
+		ctx:      ctx,
+		cancel:   cancel,
+		prefetch: options.Prefetch,
+		nextURL:  url,
+	}
+}
+
+// Next advances to the next record, fetching the next page when the current one is exhausted.
+// It returns false at the end of the result set, on error or after Close.
+func (it *QueryIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for it.index >= len(it.records) {
+		if it.started && it.nextURL == "" {
+			it.record = nil
+			return false
+		}
+		if !it.fetch() {
+			it.record = nil
+			return false
+		}
+	}
+	it.record = it.records[it.index]
+	it.index++
+	return true
+}
+
+// Record returns the current record
+func (it *QueryIterator) Record() ForceRecord {
+	return it.record
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *QueryIterator) Err() error {
+	return it.err
+}
+
+// TotalSize returns the totalSize reported by the last page fetched
+func (it *QueryIterator) TotalSize() int {
+	return it.totalSize
+}
+
+// NextRecordsURL returns the absolute URL of the page following the current one, empty on the last page
+func (it *QueryIterator) NextRecordsURL() string {
+	return it.nextURL
+}
+
+// ResumeURL returns the URL from which a new iterator delivers every record not yet returned by
+// Next. Records of a partially consumed page are delivered again.
+func (it *QueryIterator) ResumeURL() string {
+	if it.started && it.index < len(it.records) {
+		return it.pageURL
+	}
+	return it.nextURL
+}
+
+// Close cancels in-flight requests and stops prefetching. Next returns false afterwards.
+func (it *QueryIterator) Close() {
+	it.cancel()
+	if it.err == nil {
+		it.err = QueryIteratorClosedError
+	}
+}
+
+// Page loads the next page and returns its records in one slice, for callers that process whole pages
+func (it *QueryIterator) Page() (records []ForceRecord, ok bool) {
+	if it.err != nil {
+		return nil, false
+	}
+	if it.index < len(it.records) {
+		records = it.records[it.index:]
+		it.index = len(it.records)
+		return records, true
+	}
+	if it.started && it.nextURL == "" {
+		return nil, false
+	}
+	if !it.fetch() {
+		return nil, false
+	}
+	it.index = len(it.records)
+	return it.records, true
+}
+
+func (it *QueryIterator) fetch() bool {
+	var page queryPage
+	if it.pending != nil {
+		select {
+		case page = <-it.pending:
+		case <-it.ctx.Done():
+			page.err = it.ctx.Err()
+		}
+		it.pending = nil
+	} else {
+		page = it.load(it.nextURL)
+	}
+
+	if page.err != nil {
+		if it.ctx.Err() != nil && it.err == nil {
+			page.err = it.ctx.Err()
+		}
+		it.err = page.err
+		return false
+	}
+
+	it.started = true
+	it.pageURL = page.url
+	it.records = page.result.Records
+	it.index = 0
+	it.totalSize = page.result.TotalSize
+	it.nextURL = ""
+	if !page.result.Done && page.result.NextRecordsUrl != "" {
+		it.nextURL = fmt.Sprintf("%s%s", it.force.Credentials.InstanceUrl, page.result.NextRecordsUrl)
+	}
+
+	if it.prefetch && it.nextURL != "" {
+		it.pending = make(chan queryPage, 1)
+		go func(pending chan<- queryPage, url string) {
+			pending <- it.load(url)
+		}(it.pending, it.nextURL)
+	}
+	return true
+}
+
+func (it *QueryIterator) load(url string) (page queryPage) {
+	page.url = url
+	if err := it.ctx.Err(); err != nil {
+		page.err = err
+		return
+	}
+	body, err := it.force.httpGetContext(it.ctx, url, false)
+	if err != nil {
+		page.err = err
+		return
+	}
+	if err = json.Unmarshal(body, &page.result); err != nil {
+		page.err = fmt.Errorf("Error decoding query page: %w", err)
+	}
+	return
+}
+
+func queryOptionsPath(query string, options QueryOptions) string {
+	cmd := "query"
+	if options.QueryAll {
+		cmd = "queryAll"
+	}
+	if options.IsTooling {
+		cmd = "tooling/" + cmd
+	}
+	return fmt.Sprintf("/services/data/%s/%s?q=%s", apiVersion, cmd, url.QueryEscape(query))
+}
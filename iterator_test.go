@@ -0,0 +1,219 @@
+package gforce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// iteratorPages serves three pages of two, two and one records, chained through nextRecordsUrl
+var iteratorPages = map[string]string{
+	"/services/data/" + apiVersion + "/query":       `{"done":false,"totalSize":5,"nextRecordsUrl":"/services/data/` + apiVersion + `/query/01g-2","records":[{"Id":"1"},{"Id":"2"}]}`,
+	"/services/data/" + apiVersion + "/query/01g-2": `{"done":false,"totalSize":5,"nextRecordsUrl":"/services/data/` + apiVersion + `/query/01g-4","records":[{"Id":"3"},{"Id":"4"}]}`,
+	"/services/data/" + apiVersion + "/query/01g-4": `{"done":true,"totalSize":5,"records":[{"Id":"5"}]}`,
+}
+
+func serveIteratorPages(w http.ResponseWriter, r *http.Request) {
+	page, ok := iteratorPages[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprint(w, page)
+}
+
+func iteratorIds(it *QueryIterator) (ids []string) {
+	for it.Next() {
+		ids = append(ids, fmt.Sprint(it.Record()["Id"]))
+	}
+	return
+}
+
+func TestQueryIteratorNext(t *testing.T) {
+	f := newTestForce(t, serveIteratorPages)
+
+	it := f.NewQueryIterator(context.Background(), "SELECT Id FROM Account")
+	defer it.Close()
+	ids := iteratorIds(it)
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration error '%v'", err)
+	}
+	if expected := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("ids '%v', expected '%v'", ids, expected)
+	}
+	if it.TotalSize() != 5 {
+		t.Errorf("total size '%v', expected '%v'", it.TotalSize(), 5)
+	}
+	if it.NextRecordsURL() != "" {
+		t.Errorf("next records url '%v' after the last page", it.NextRecordsURL())
+	}
+}
+
+func TestQueryIteratorPage(t *testing.T) {
+	f := newTestForce(t, serveIteratorPages)
+
+	it := f.NewQueryIterator(context.Background(), "SELECT Id FROM Account")
+	defer it.Close()
+	var sizes []int
+	for {
+		records, ok := it.Page()
+		if !ok {
+			break
+		}
+		sizes = append(sizes, len(records))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration error '%v'", err)
+	}
+	if expected := []int{2, 2, 1}; !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("page sizes '%v', expected '%v'", sizes, expected)
+	}
+}
+
+func TestQueryIteratorResume(t *testing.T) {
+	f := newTestForce(t, serveIteratorPages)
+
+	type resumeItem struct {
+		read int
+		ids  []string
+	}
+	table := []resumeItem{
+		{read: 0, ids: []string{"1", "2", "3", "4", "5"}},
+		{read: 2, ids: []string{"3", "4", "5"}},
+		{read: 3, ids: []string{"3", "4", "5"}},
+		{read: 4, ids: []string{"5"}},
+	}
+	for _, tt := range table {
+		it := f.NewQueryIterator(context.Background(), "SELECT Id FROM Account")
+		for i := 0; i < tt.read; i++ {
+			it.Next()
+		}
+		resumeURL := it.ResumeURL()
+		it.Close()
+
+		resumed := f.ResumeQueryIterator(context.Background(), resumeURL)
+		ids := iteratorIds(resumed)
+		resumed.Close()
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("resumed after %v records '%v', expected '%v'", tt.read, ids, tt.ids)
+		}
+	}
+
+	relative := f.ResumeQueryIterator(context.Background(), "/services/data/"+apiVersion+"/query/01g-4")
+	defer relative.Close()
+	if ids := iteratorIds(relative); !reflect.DeepEqual(ids, []string{"5"}) {
+		t.Errorf("resumed from relative url '%v', expected '%v'", ids, []string{"5"})
+	}
+}
+
+func TestQueryIteratorPrefetch(t *testing.T) {
+	requested := make(chan string, len(iteratorPages))
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Path
+		serveIteratorPages(w, r)
+	})
+
+	it := f.NewQueryIterator(context.Background(), "SELECT Id FROM Account", WithPrefetch)
+	defer it.Close()
+	if !it.Next() {
+		t.Fatalf("no first record, error '%v'", it.Err())
+	}
+	<-requested
+	select {
+	case path := <-requested:
+		if !strings.HasSuffix(path, "/query/01g-2") {
+			t.Errorf("prefetched '%v', expected the second page", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second page not prefetched while the first one is read")
+	}
+
+	ids := append([]string{"1"}, iteratorIds(it)...)
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration error '%v'", err)
+	}
+	if expected := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("ids '%v', expected '%v'", ids, expected)
+	}
+}
+
+func TestQueryIteratorClose(t *testing.T) {
+	started, aborted := make(chan struct{}), make(chan struct{})
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/query") {
+			serveIteratorPages(w, r)
+			return
+		}
+		close(started)
+		<-r.Context().Done()
+		close(aborted)
+	})
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	goroutines := runtime.NumGoroutine()
+
+	it := f.NewQueryIterator(context.Background(), "SELECT Id FROM Account", WithPrefetch)
+	if !it.Next() {
+		t.Fatalf("no first record, error '%v'", it.Err())
+	}
+	<-started
+	it.Close()
+	if it.Next() {
+		t.Error("Next returned a record after Close")
+	}
+	if it.Err() != QueryIteratorClosedError {
+		t.Errorf("error '%v', expected '%v'", it.Err(), QueryIteratorClosedError)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prefetch request not cancelled by Close")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("%v goroutines after Close, expected at most %v", n, goroutines)
+	}
+}
+
+func TestQueryIteratorError(t *testing.T) {
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/query/01g-2") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `[{"message":"invalid query locator","errorCode":"INVALID_QUERY_LOCATOR"}]`)
+			return
+		}
+		serveIteratorPages(w, r)
+	})
+
+	for _, prefetch := range []bool{false, true} {
+		options := []func(*QueryOptions){}
+		if prefetch {
+			options = append(options, WithPrefetch)
+		}
+		it := f.NewQueryIterator(context.Background(), "SELECT Id FROM Account", options...)
+		ids := iteratorIds(it)
+		if expected := []string{"1", "2"}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("prefetch %v: ids '%v', expected '%v'", prefetch, ids, expected)
+		}
+		if it.Err() == nil || it.Err().Error() != "invalid query locator" {
+			t.Errorf("prefetch %v: error '%v', expected '%v'", prefetch, it.Err(), "invalid query locator")
+		}
+		if it.Next() {
+			t.Errorf("prefetch %v: Next returned a record after an error", prefetch)
+		}
+		it.Close()
+		if !strings.HasSuffix(it.ResumeURL(), "/query/01g-2") {
+			t.Errorf("prefetch %v: resume url '%v', expected the failing page", prefetch, it.ResumeURL())
+		}
+	}
+}
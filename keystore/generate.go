@@ -0,0 +1,127 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+const (
+	defaultKeySize  = 2048
+	defaultValidity = 365 * 24 * time.Hour
+)
+
+// CertificateOptions configures GenerateSelfSigned
+// Zero values fall back to a 2048 bits key valid for one year starting now
+type CertificateOptions struct {
+	CommonName         string
+	Organization       string
+	OrganizationalUnit string
+	Country            string
+	KeySize            int
+	NotBefore          time.Time
+	Validity           time.Duration
+	Rand               io.Reader
+}
+
+// GenerateSelfSigned creates an RSA key pair and a self-signed X.509 certificate for it
+// and returns them as a private key entry whose chain holds only that certificate
+func GenerateSelfSigned(opts CertificateOptions) (*PrivateKeyEntry, error) {
+	if opts.CommonName == "" {
+		return nil, errors.New("got empty common name")
+	}
+	if opts.KeySize == 0 {
+		opts.KeySize = defaultKeySize
+	}
+	if opts.Validity == 0 {
+		opts.Validity = defaultValidity
+	}
+	if opts.Validity < 0 {
+		return nil, errors.New("got negative validity")
+	}
+	if opts.NotBefore.IsZero() {
+		opts.NotBefore = time.Now()
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Reader
+	}
+
+	key, err := rsa.GenerateKey(opts.Rand, opts.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("generate rsa key: %w", err)
+	}
+	serial, err := rand.Int(opts.Rand, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	subject := pkix.Name{CommonName: opts.CommonName}
+	if opts.Organization != "" {
+		subject.Organization = []string{opts.Organization}
+	}
+	if opts.OrganizationalUnit != "" {
+		subject.OrganizationalUnit = []string{opts.OrganizationalUnit}
+	}
+	if opts.Country != "" {
+		subject.Country = []string{opts.Country}
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             opts.NotBefore,
+		NotAfter:              opts.NotBefore.Add(opts.Validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+	}
+	certificate, err := x509.CreateCertificate(opts.Rand, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+	privateKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	return &PrivateKeyEntry{
+		Entry: Entry{
+			CreationTime: time.Now(),
+		},
+		PrivateKey: privateKey,
+		CertificateChain: []Certificate{{
+			Type:    defaultCertificateType,
+			Content: certificate,
+		}},
+	}, nil
+}
+
+// EncodeCertificatePEM writes the leaf certificate of entry into w as a single PEM block,
+// which is the format expected when uploading a certificate to a Connected App
+func EncodeCertificatePEM(w io.Writer, entry *PrivateKeyEntry) error {
+	if len(entry.CertificateChain) == 0 {
+		return errors.New("got empty certificate chain")
+	}
+	if err := pem.Encode(w, &pem.Block{Type: pemCertificateType, Bytes: entry.CertificateChain[0].Content}); err != nil {
+		return fmt.Errorf("write certificate: %w", err)
+	}
+	return nil
+}
+
+// ExpiringBefore describes the entries whose leaf certificate is no longer valid at deadline
+func (ks KeyStore) ExpiringBefore(deadline time.Time) ([]EntryInfo, error) {
+	infos, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+	var expiring []EntryInfo
+	for _, info := range infos {
+		if !info.NotAfter.IsZero() && info.NotAfter.Before(deadline) {
+			expiring = append(expiring, info)
+		}
+	}
+	return expiring, nil
+}
@@ -0,0 +1,62 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSigned(t *testing.T) {
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	entry, err := GenerateSelfSigned(CertificateOptions{
+		CommonName:   "job",
+		Organization: "gforce",
+		KeySize:      1024,
+		NotBefore:    notBefore,
+		Validity:     48 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(entry.PrivateKey); err != nil {
+		t.Errorf("private key is not pkcs8: %v", err)
+	}
+	if err := checkKeyPair(entry.PrivateKey, entry.CertificateChain[0]); err != nil {
+		t.Errorf("check key pair: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeCertificatePEM(&buf, entry); err != nil {
+		t.Fatalf("encode certificate: %v", err)
+	}
+	chain, err := ParsePEMCertificates(buf.Bytes())
+	if err != nil || len(chain) != 1 {
+		t.Fatalf("parse certificate pem '%v' '%v'", chain, err)
+	}
+
+	ks := KeyStore{"job_certificate": entry}
+	info, err := ks.Info("job_certificate")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.Subject != "CN=job,O=gforce" {
+		t.Errorf("invalid subject '%v'", info.Subject)
+	}
+	if !info.NotAfter.Equal(notBefore.Add(48 * time.Hour)) {
+		t.Errorf("invalid expiry '%v'", info.NotAfter)
+	}
+
+	expiring, err := ks.ExpiringBefore(time.Now().Add(24 * time.Hour))
+	if err != nil || len(expiring) != 0 {
+		t.Errorf("unexpected expiring entries '%v' '%v'", expiring, err)
+	}
+	expiring, err = ks.ExpiringBefore(time.Now().Add(72 * time.Hour))
+	if err != nil || len(expiring) != 1 {
+		t.Errorf("expected expiring entry '%v' '%v'", expiring, err)
+	}
+
+	if _, err := GenerateSelfSigned(CertificateOptions{}); err == nil {
+		t.Errorf("expected error for empty common name")
+	}
+}
@@ -0,0 +1,85 @@
+package keystore
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// PrivateKeyEntryType names entries holding a private key and its certificate chain
+	PrivateKeyEntryType = "PrivateKeyEntry"
+	// TrustedCertificateEntryType names entries holding a single trusted certificate
+	TrustedCertificateEntryType = "trustedCertEntry"
+)
+
+// EntryInfo summarizes a keystore entry without exposing key material
+type EntryInfo struct {
+	Alias        string
+	Type         string
+	CreationTime time.Time
+	Subject      string
+	Issuer       string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	ChainLength  int
+}
+
+// Aliases returns the keystore aliases sorted alphabetically
+func (ks KeyStore) Aliases() []string {
+	aliases := make([]string, 0, len(ks))
+	for alias := range ks {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// Info describes the entry stored under alias, reading subject and validity from its leaf certificate
+func (ks KeyStore) Info(alias string) (EntryInfo, error) {
+	info := EntryInfo{Alias: alias}
+	var leaf Certificate
+	switch typedEntry := ks[alias].(type) {
+	case *PrivateKeyEntry:
+		info.Type = PrivateKeyEntryType
+		info.CreationTime = typedEntry.CreationTime
+		info.ChainLength = len(typedEntry.CertificateChain)
+		if info.ChainLength == 0 {
+			return info, nil
+		}
+		leaf = typedEntry.CertificateChain[0]
+	case *TrustedCertificateEntry:
+		info.Type = TrustedCertificateEntryType
+		info.CreationTime = typedEntry.CreationTime
+		info.ChainLength = 1
+		leaf = typedEntry.Certificate
+	case nil:
+		return info, fmt.Errorf("got no entry for alias %q", alias)
+	default:
+		return info, fmt.Errorf("got invalid entry for alias %q", alias)
+	}
+	certificate, err := x509.ParseCertificate(leaf.Content)
+	if err != nil {
+		return info, fmt.Errorf("parse certificate of %q: %w", alias, err)
+	}
+	info.Subject = certificate.Subject.String()
+	info.Issuer = certificate.Issuer.String()
+	info.NotBefore = certificate.NotBefore
+	info.NotAfter = certificate.NotAfter
+	return info, nil
+}
+
+// List describes every entry of the keystore sorted by alias
+func (ks KeyStore) List() ([]EntryInfo, error) {
+	aliases := ks.Aliases()
+	infos := make([]EntryInfo, 0, len(aliases))
+	for _, alias := range aliases {
+		info, err := ks.Info(alias)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,151 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	pemPrivateKeyType    = "PRIVATE KEY"
+	pemRSAPrivateKeyType = "RSA PRIVATE KEY"
+	pemECPrivateKeyType  = "EC PRIVATE KEY"
+	pemCertificateType   = "CERTIFICATE"
+)
+
+// ParsePEMPrivateKey reads the first private key block found in data and returns it as PKCS#8 DER,
+// which is the representation stored in PrivateKeyEntry.PrivateKey
+func ParsePEMPrivateKey(data []byte) ([]byte, error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case pemPrivateKeyType:
+			if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("parse pkcs8 private key: %w", err)
+			}
+			return block.Bytes, nil
+		case pemRSAPrivateKeyType:
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse pkcs1 private key: %w", err)
+			}
+			return x509.MarshalPKCS8PrivateKey(key)
+		case pemECPrivateKeyType:
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse ec private key: %w", err)
+			}
+			return x509.MarshalPKCS8PrivateKey(key)
+		}
+	}
+	return nil, errors.New("got no private key block")
+}
+
+// ParsePEMCertificates reads every certificate block found in data in order of appearance
+func ParsePEMCertificates(data []byte) ([]Certificate, error) {
+	var chain []Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != pemCertificateType {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("parse %d certificate: %w", len(chain), err)
+		}
+		chain = append(chain, Certificate{
+			Type:    defaultCertificateType,
+			Content: block.Bytes,
+		})
+	}
+	return chain, nil
+}
+
+// NewPrivateKeyEntryFromPEM builds a private key entry from a PEM private key and its PEM certificate chain
+// The first certificate of the chain must hold the public key matching the private key
+func NewPrivateKeyEntryFromPEM(keyPEM, chainPEM []byte, creationTime time.Time) (*PrivateKeyEntry, error) {
+	privateKey, err := ParsePEMPrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	chain, err := ParsePEMCertificates(chainPEM)
+	if err != nil {
+		return nil, fmt.Errorf("read certificate chain: %w", err)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("got empty certificate chain")
+	}
+	if err := checkKeyPair(privateKey, chain[0]); err != nil {
+		return nil, err
+	}
+	return &PrivateKeyEntry{
+		Entry: Entry{
+			CreationTime: creationTime,
+		},
+		PrivateKey:       privateKey,
+		CertificateChain: chain,
+	}, nil
+}
+
+// NewTrustedCertificateEntryFromPEM builds a trusted certificate entry from the first PEM certificate in data
+func NewTrustedCertificateEntryFromPEM(data []byte, creationTime time.Time) (*TrustedCertificateEntry, error) {
+	chain, err := ParsePEMCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("read certificate: %w", err)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("got no certificate block")
+	}
+	return &TrustedCertificateEntry{
+		Entry: Entry{
+			CreationTime: creationTime,
+		},
+		Certificate: chain[0],
+	}, nil
+}
+
+// EncodePEM writes entry into w as PEM blocks, the private key first (PKCS#8) followed by the certificates
+func EncodePEM(w io.Writer, entry interface{}) error {
+	switch typedEntry := entry.(type) {
+	case *PrivateKeyEntry:
+		if err := pem.Encode(w, &pem.Block{Type: pemPrivateKeyType, Bytes: typedEntry.PrivateKey}); err != nil {
+			return fmt.Errorf("write private key: %w", err)
+		}
+		for i, cert := range typedEntry.CertificateChain {
+			if err := pem.Encode(w, &pem.Block{Type: pemCertificateType, Bytes: cert.Content}); err != nil {
+				return fmt.Errorf("write %d certificate: %w", i, err)
+			}
+		}
+	case *TrustedCertificateEntry:
+		if err := pem.Encode(w, &pem.Block{Type: pemCertificateType, Bytes: typedEntry.Certificate.Content}); err != nil {
+			return fmt.Errorf("write certificate: %w", err)
+		}
+	default:
+		return errors.New("got invalid entry")
+	}
+	return nil
+}
+
+func checkKeyPair(privateKey []byte, cert Certificate) error {
+	key, err := x509.ParsePKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("parse private key: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(cert.Content)
+	if err != nil {
+		return fmt.Errorf("parse certificate: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.New("got unsupported private key type")
+	}
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return errors.New("got unsupported private key type")
+	}
+	if !public.Equal(certificate.PublicKey) {
+		return errors.New("got private key not matching certificate")
+	}
+	return nil
+}
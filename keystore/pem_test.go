@@ -0,0 +1,123 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, cn string) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return key, der
+}
+
+func TestPrivateKeyEntryPEMRoundTrip(t *testing.T) {
+	key, der := newTestCertificate(t, "job")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	entry, err := NewPrivateKeyEntryFromPEM(keyPEM, certPEM, time.Now())
+	if err != nil {
+		t.Fatalf("new private key entry: %v", err)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(entry.PrivateKey); err != nil {
+		t.Errorf("private key is not pkcs8: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodePEM(&buf, entry); err != nil {
+		t.Fatalf("encode pem: %v", err)
+	}
+	decoded, err := NewPrivateKeyEntryFromPEM(buf.Bytes(), buf.Bytes(), entry.CreationTime)
+	if err != nil {
+		t.Fatalf("decode exported pem: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, entry) {
+		t.Errorf("invalid round trip '%v' '%v'", decoded, entry)
+	}
+}
+
+func TestPrivateKeyEntryFromPEMMismatch(t *testing.T) {
+	key, _ := newTestCertificate(t, "key")
+	_, der := newTestCertificate(t, "other")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	if _, err := NewPrivateKeyEntryFromPEM(keyPEM, certPEM, time.Now()); err == nil {
+		t.Errorf("expected error for mismatched key pair")
+	}
+	if _, err := NewPrivateKeyEntryFromPEM(keyPEM, nil, time.Now()); err == nil {
+		t.Errorf("expected error for empty chain")
+	}
+}
+
+func TestKeyStoreList(t *testing.T) {
+	key, der := newTestCertificate(t, "job")
+	_, caDer := newTestCertificate(t, "ca")
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	created := time.Now().Truncate(time.Millisecond)
+	ks := KeyStore{
+		"job": &PrivateKeyEntry{
+			Entry:            Entry{CreationTime: created},
+			PrivateKey:       pkcs8,
+			CertificateChain: []Certificate{{Type: defaultCertificateType, Content: der}},
+		},
+		"ca": &TrustedCertificateEntry{
+			Entry:       Entry{CreationTime: created},
+			Certificate: Certificate{Type: defaultCertificateType, Content: caDer},
+		},
+	}
+
+	var buf bytes.Buffer
+	password := []byte("password")
+	if err := Encode(&buf, ks, password); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := Decode(&buf, password)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	infos, err := decoded.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Alias != "ca" || infos[1].Alias != "job" {
+		t.Fatalf("invalid aliases '%v'", infos)
+	}
+	if infos[0].Type != TrustedCertificateEntryType || infos[1].Type != PrivateKeyEntryType {
+		t.Errorf("invalid types '%v' '%v'", infos[0].Type, infos[1].Type)
+	}
+	if infos[1].Subject != "CN=job" || infos[1].NotAfter.IsZero() {
+		t.Errorf("invalid certificate info '%v'", infos[1])
+	}
+	if !infos[1].CreationTime.Equal(created) {
+		t.Errorf("invalid creation time '%v' '%v'", infos[1].CreationTime, created)
+	}
+	if _, err := decoded.Info("missing"); err == nil {
+		t.Errorf("expected error for missing alias")
+	}
+}
@@ -127,7 +127,9 @@ func NewForceMetadata(force *Force) (fm *ForceMetadata) {
 }
 
 func (fm *ForceMetadata) CheckStatus(id string) (err error) {
-	body, err := fm.soapExecute("checkStatus", fmt.Sprintf("<id>%s</id>", id))
+	body, err := fm.soapExecute("checkStatus", struct {
+		Id string `xml:"id"`
+	}{id}, false)
 	if err != nil {
 		return
 	}
@@ -164,7 +166,9 @@ func (results ForceCheckDeploymentStatusResult) String() string {
 }
 
 func (fm *ForceMetadata) DescribeMetadata() (describe MetadataDescribeResult, err error) {
-	body, err := fm.soapExecute("describeMetadata", fmt.Sprintf("<apiVersion>%s</apiVersion>", apiVersionNumber))
+	body, err := fm.soapExecute("describeMetadata", struct {
+		ApiVersion string `xml:"apiVersion"`
+	}{apiVersionNumber}, false)
 	if err != nil {
 		return
 	}
@@ -183,13 +187,15 @@ func (fm *ForceMetadata) DescribeMetadata() (describe MetadataDescribeResult, er
 	return
 }
 
-func (fm *ForceMetadata) soapExecute(action, query string) (response []byte, err error) {
+func (fm *ForceMetadata) soapExecute(action string, request interface{}, refreshed bool) (response []byte, err error) {
 	url := fmt.Sprintf("%s/services/Soap/m/%s", fm.Force.Credentials.InstanceUrl, fm.ApiVersion)
 	soap := NewSoap(url, "http://soap.sforce.com/2006/04/metadata", fm.Force.Credentials.AccessToken)
-	response, err = soap.Execute(action, query)
-	if err == SessionExpiredError {
-		fm.Force.RefreshSession()
-		return fm.soapExecute(action, query)
+	response, err = soap.ExecuteRequest(action, request)
+	if err == SessionExpiredError && !refreshed {
+		if e := fm.Force.RefreshSession(); e != nil {
+			return nil, e
+		}
+		return fm.soapExecute(action, request, true)
 	}
 	return
 }
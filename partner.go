@@ -8,6 +8,9 @@ import (
 
 type ForcePartner struct {
 	Force *Force
+	// Headers are sent with the partner API calls (Merge, Undelete, ConvertLead...), e.g.
+	// AllOrNoneHeader or CallOptions
+	Headers []interface{}
 }
 
 func NewForcePartner(force *Force) (partner *ForcePartner) {
@@ -16,7 +19,9 @@ func NewForcePartner(force *Force) (partner *ForcePartner) {
 }
 
 func (partner *ForcePartner) CheckStatus(id string) (err error) {
-	body, err := partner.soapExecute("checkStatus", fmt.Sprintf("<id>%s</id>", id))
+	body, err := partner.soapExecute("checkStatus", struct {
+		Id string `xml:"id"`
+	}{id}, false)
 	if err != nil {
 		return
 	}
@@ -41,16 +46,18 @@ func (partner *ForcePartner) CheckStatus(id string) (err error) {
 	return
 }
 
-func (partner *ForcePartner) soapExecute(action, query string) (response []byte, err error) {
+func (partner *ForcePartner) soapExecute(action string, request interface{}, refreshed bool) (response []byte, err error) {
 	url := fmt.Sprintf("%s/services/Soap/s/%s/%s", partner.Force.Credentials.InstanceUrl, partner.Force.Credentials.SessionOptions.ApiVersion, partner.Force.Credentials.UserInfo.OrgId)
-	soap := NewSoap(url, "http://soap.sforce.com/2006/08/apex", partner.Force.Credentials.AccessToken)
-	soap.Header = "<apex:DebuggingHeader><apex:debugLevel>DEBUGONLY</apex:debugLevel></apex:DebuggingHeader>"
+	soap := NewSoap(url, apexNamespace, partner.Force.Credentials.AccessToken)
+	soap.Headers = []interface{}{DebuggingHeader{DebugLevel: "DEBUGONLY"}}
 
-	response, err = soap.Execute(action, query)
+	response, err = soap.ExecuteRequest(action, request)
 
-	if err == SessionExpiredError {
-		partner.Force.RefreshSession()
-		return partner.soapExecute(action, query)
+	if err == SessionExpiredError && !refreshed {
+		if e := partner.Force.RefreshSession(); e != nil {
+			return nil, e
+		}
+		return partner.soapExecute(action, request, true)
 	}
 
 	return
@@ -0,0 +1,467 @@
+package gforce
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	partnerNamespace        = "urn:partner.soap.sforce.com"
+	partnerSObjectNamespace = "urn:sobject.partner.soap.sforce.com"
+	maxDescribeSObjects     = 100
+	maxPartnerRecords       = 200
+	maxMergeRecords         = 2
+)
+
+var PartnerLimitError = errors.New("Too many records for a partner call")
+
+// PartnerResult is the outcome of undelete and emptyRecycleBin for one Id
+type PartnerResult struct {
+	Id      string       `xml:"id"`
+	Success bool         `xml:"success"`
+	Errors  []ForceError `xml:"errors"`
+}
+
+// MergeRequest merges up to two records of SObject into MasterRecord, which holds the Id of the
+// surviving record and the field values it should keep
+type MergeRequest struct {
+	SObject          string
+	MasterRecord     ForceRecord
+	RecordToMergeIds []string
+}
+
+type MergeResult struct {
+	Id                string       `xml:"id"`
+	MergedRecordIds   []string     `xml:"mergedRecordIds"`
+	UpdatedRelatedIds []string     `xml:"updatedRelatedIds"`
+	Success           bool         `xml:"success"`
+	Errors            []ForceError `xml:"errors"`
+}
+
+// LeadConvert converts LeadId, into AccountId and ContactId when set instead of new records
+type LeadConvert struct {
+	XMLName                xml.Name `xml:"leadConverts"`
+	AccountId              string   `xml:"accountId,omitempty"`
+	ContactId              string   `xml:"contactId,omitempty"`
+	ConvertedStatus        string   `xml:"convertedStatus"`
+	DoNotCreateOpportunity bool     `xml:"doNotCreateOpportunity"`
+	LeadId                 string   `xml:"leadId"`
+	OpportunityName        string   `xml:"opportunityName,omitempty"`
+	OverwriteLeadSource    bool     `xml:"overwriteLeadSource"`
+	OwnerId                string   `xml:"ownerId,omitempty"`
+	SendNotificationEmail  bool     `xml:"sendNotificationEmail"`
+}
+
+type LeadConvertResult struct {
+	AccountId     string       `xml:"accountId"`
+	ContactId     string       `xml:"contactId"`
+	LeadId        string       `xml:"leadId"`
+	OpportunityId string       `xml:"opportunityId"`
+	Success       bool         `xml:"success"`
+	Errors        []ForceError `xml:"errors"`
+}
+
+// ProcessSubmitRequest submits ObjectId for approval
+type ProcessSubmitRequest struct {
+	XMLName                   xml.Name `xml:"actions"`
+	Comments                  string   `xml:"comments,omitempty"`
+	NextApproverIds           []string `xml:"nextApproverIds,omitempty"`
+	ObjectId                  string   `xml:"objectId"`
+	SubmitterId               string   `xml:"submitterId,omitempty"`
+	ProcessDefinitionNameOrId string   `xml:"processDefinitionNameOrId,omitempty"`
+	SkipEntryCriteria         bool     `xml:"skipEntryCriteria,omitempty"`
+}
+
+// ProcessWorkitemRequest approves, rejects or removes (Action Approve, Reject, Removed) a
+// pending approval work item
+type ProcessWorkitemRequest struct {
+	XMLName         xml.Name `xml:"actions"`
+	Comments        string   `xml:"comments,omitempty"`
+	NextApproverIds []string `xml:"nextApproverIds,omitempty"`
+	Action          string   `xml:"action"`
+	WorkitemId      string   `xml:"workitemId"`
+}
+
+// MarshalXML writes the request with the xsi:type the process call needs to tell actions apart
+func (r ProcessSubmitRequest) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type request ProcessSubmitRequest
+	return e.EncodeElement(request(r), processAction("ProcessSubmitRequest"))
+}
+
+// MarshalXML writes the request with the xsi:type the process call needs to tell actions apart
+func (r ProcessWorkitemRequest) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type request ProcessWorkitemRequest
+	return e.EncodeElement(request(r), processAction("ProcessWorkitemRequest"))
+}
+
+// processAction is the actions element of a process call holding a request of xsiType
+func processAction(xsiType string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: "actions"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xsi:type"}, Value: xsiType}}}
+}
+
+type ProcessResult struct {
+	ActorIds       []string     `xml:"actorIds"`
+	EntityId       string       `xml:"entityId"`
+	InstanceId     string       `xml:"instanceId"`
+	InstanceStatus string       `xml:"instanceStatus"`
+	NewWorkitemIds []string     `xml:"newWorkitemIds"`
+	Success        bool         `xml:"success"`
+	Errors         []ForceError `xml:"errors"`
+}
+
+// PartnerUserInfo is the result of getUserInfo
+type PartnerUserInfo struct {
+	AccessibilityMode          bool   `xml:"accessibilityMode"`
+	CurrencySymbol             string `xml:"currencySymbol"`
+	OrgAttachmentFileSizeLimit int    `xml:"orgAttachmentFileSizeLimit"`
+	OrgDefaultCurrencyIsoCode  string `xml:"orgDefaultCurrencyIsoCode"`
+	OrgDisallowHtmlAttachments bool   `xml:"orgDisallowHtmlAttachments"`
+	OrgHasPersonAccounts       bool   `xml:"orgHasPersonAccounts"`
+	OrganizationId             string `xml:"organizationId"`
+	OrganizationMultiCurrency  bool   `xml:"organizationMultiCurrency"`
+	OrganizationName           string `xml:"organizationName"`
+	ProfileId                  string `xml:"profileId"`
+	RoleId                     string `xml:"roleId"`
+	SessionSecondsValid        int    `xml:"sessionSecondsValid"`
+	UserDefaultCurrencyIsoCode string `xml:"userDefaultCurrencyIsoCode"`
+	UserEmail                  string `xml:"userEmail"`
+	UserFullName               string `xml:"userFullName"`
+	UserId                     string `xml:"userId"`
+	UserLanguage               string `xml:"userLanguage"`
+	UserLocale                 string `xml:"userLocale"`
+	UserName                   string `xml:"userName"`
+	UserTimeZone               string `xml:"userTimeZone"`
+	UserType                   string `xml:"userType"`
+	UserUiSkin                 string `xml:"userUiSkin"`
+}
+
+// SObjectDescribe is the part of a describeSObjects result most callers need
+type SObjectDescribe struct {
+	Name               string                  `xml:"name"`
+	Label              string                  `xml:"label"`
+	LabelPlural        string                  `xml:"labelPlural"`
+	KeyPrefix          string                  `xml:"keyPrefix"`
+	Custom             bool                    `xml:"custom"`
+	Createable         bool                    `xml:"createable"`
+	Updateable         bool                    `xml:"updateable"`
+	Deletable          bool                    `xml:"deletable"`
+	Queryable          bool                    `xml:"queryable"`
+	Mergeable          bool                    `xml:"mergeable"`
+	Undeletable        bool                    `xml:"undeletable"`
+	Fields             []SObjectFieldDescribe  `xml:"fields"`
+	ChildRelationships []SObjectChildDescribe  `xml:"childRelationships"`
+	RecordTypeInfos    []SObjectRecordTypeInfo `xml:"recordTypeInfos"`
+}
+
+type SObjectFieldDescribe struct {
+	Name             string                 `xml:"name"`
+	Label            string                 `xml:"label"`
+	Type             string                 `xml:"type"`
+	SoapType         string                 `xml:"soapType"`
+	Length           int                    `xml:"length"`
+	Precision        int                    `xml:"precision"`
+	Scale            int                    `xml:"scale"`
+	Nillable         bool                   `xml:"nillable"`
+	Createable       bool                   `xml:"createable"`
+	Updateable       bool                   `xml:"updateable"`
+	Custom           bool                   `xml:"custom"`
+	ExternalId       bool                   `xml:"externalId"`
+	IdLookup         bool                   `xml:"idLookup"`
+	Unique           bool                   `xml:"unique"`
+	Calculated       bool                   `xml:"calculated"`
+	ReferenceTo      []string               `xml:"referenceTo"`
+	RelationshipName string                 `xml:"relationshipName"`
+	PicklistValues   []SObjectPicklistEntry `xml:"picklistValues"`
+}
+
+type SObjectPicklistEntry struct {
+	Active       bool   `xml:"active"`
+	DefaultValue bool   `xml:"defaultValue"`
+	Label        string `xml:"label"`
+	Value        string `xml:"value"`
+}
+
+type SObjectChildDescribe struct {
+	ChildSObject     string `xml:"childSObject"`
+	Field            string `xml:"field"`
+	RelationshipName string `xml:"relationshipName"`
+	CascadeDelete    bool   `xml:"cascadeDelete"`
+}
+
+type SObjectRecordTypeInfo struct {
+	Name                     string `xml:"name"`
+	DeveloperName            string `xml:"developerName"`
+	RecordTypeId             string `xml:"recordTypeId"`
+	Available                bool   `xml:"available"`
+	DefaultRecordTypeMapping bool   `xml:"defaultRecordTypeMapping"`
+	Master                   bool   `xml:"master"`
+}
+
+// Err returns a *SubrequestError when the record failed
+func (r PartnerResult) Err() error {
+	return partnerErr(r.Success, r.Id, r.Errors)
+}
+
+func (r MergeResult) Err() error {
+	return partnerErr(r.Success, r.Id, r.Errors)
+}
+
+func (r LeadConvertResult) Err() error {
+	return partnerErr(r.Success, r.LeadId, r.Errors)
+}
+
+func (r ProcessResult) Err() error {
+	return partnerErr(r.Success, r.EntityId, r.Errors)
+}
+
+func partnerErr(success bool, id string, errs []ForceError) error {
+	if success {
+		return nil
+	}
+	return &SubrequestError{ReferenceId: id, Errors: errs}
+}
+
+// Merge merges records, at most 200 requests of up to two records each
+func (partner *ForcePartner) Merge(requests []MergeRequest) (results []MergeResult, err error) {
+	if len(requests) > maxPartnerRecords {
+		return nil, fmt.Errorf("%w: %d merge requests, the maximum is %d", PartnerLimitError, len(requests), maxPartnerRecords)
+	}
+	type mergeRequest struct {
+		XMLName          xml.Name       `xml:"request"`
+		MasterRecord     partnerSObject `xml:"masterRecord"`
+		RecordToMergeIds []string       `xml:"recordToMergeIds"`
+	}
+	body := make([]mergeRequest, len(requests))
+	for i, request := range requests {
+		if len(request.RecordToMergeIds) > maxMergeRecords {
+			return nil, fmt.Errorf("%w: %d records to merge, the maximum is %d", PartnerLimitError, len(request.RecordToMergeIds), maxMergeRecords)
+		}
+		body[i] = mergeRequest{MasterRecord: partnerSObject{SObject: request.SObject, Record: request.MasterRecord}, RecordToMergeIds: request.RecordToMergeIds}
+	}
+	var response struct {
+		Results []MergeResult `xml:"Body>mergeResponse>result"`
+	}
+	err = partner.partnerExecute("merge", body, &response)
+	results = response.Results
+	return
+}
+
+// Undelete restores records from the recycle bin
+func (partner *ForcePartner) Undelete(ids []string) (results []PartnerResult, err error) {
+	var response struct {
+		Results []PartnerResult `xml:"Body>undeleteResponse>result"`
+	}
+	err = partner.idsExecute("undelete", ids, &response)
+	results = response.Results
+	return
+}
+
+// EmptyRecycleBin deletes records from the recycle bin for good
+func (partner *ForcePartner) EmptyRecycleBin(ids []string) (results []PartnerResult, err error) {
+	var response struct {
+		Results []PartnerResult `xml:"Body>emptyRecycleBinResponse>result"`
+	}
+	err = partner.idsExecute("emptyRecycleBin", ids, &response)
+	results = response.Results
+	return
+}
+
+func (partner *ForcePartner) ConvertLead(leadConverts []LeadConvert) (results []LeadConvertResult, err error) {
+	if len(leadConverts) > maxPartnerRecords {
+		return nil, fmt.Errorf("%w: %d leads, the maximum is %d", PartnerLimitError, len(leadConverts), maxPartnerRecords)
+	}
+	var response struct {
+		Results []LeadConvertResult `xml:"Body>convertLeadResponse>result"`
+	}
+	err = partner.partnerExecute("convertLead", leadConverts, &response)
+	results = response.Results
+	return
+}
+
+// SubmitForApproval submits records to their approval process
+func (partner *ForcePartner) SubmitForApproval(requests []ProcessSubmitRequest) (results []ProcessResult, err error) {
+	return partner.process(requests)
+}
+
+// ProcessWorkitems approves, rejects or removes pending approvals
+func (partner *ForcePartner) ProcessWorkitems(requests []ProcessWorkitemRequest) (results []ProcessResult, err error) {
+	return partner.process(requests)
+}
+
+func (partner *ForcePartner) process(actions interface{}) (results []ProcessResult, err error) {
+	var response struct {
+		Results []ProcessResult `xml:"Body>processResponse>result"`
+	}
+	err = partner.partnerExecute("process", actions, &response)
+	results = response.Results
+	return
+}
+
+func (partner *ForcePartner) GetServerTimestamp() (timestamp time.Time, err error) {
+	var response struct {
+		Timestamp time.Time `xml:"Body>getServerTimestampResponse>result>timestamp"`
+	}
+	err = partner.partnerExecute("getServerTimestamp", nil, &response)
+	timestamp = response.Timestamp
+	return
+}
+
+func (partner *ForcePartner) GetUserInfo() (info PartnerUserInfo, err error) {
+	var response struct {
+		Info PartnerUserInfo `xml:"Body>getUserInfoResponse>result"`
+	}
+	err = partner.partnerExecute("getUserInfo", nil, &response)
+	info = response.Info
+	return
+}
+
+// DescribeSObjects describes sobjects, 100 per call
+func (partner *ForcePartner) DescribeSObjects(sobjects ...string) (describes []SObjectDescribe, err error) {
+	for start := 0; start < len(sobjects); start += maxDescribeSObjects {
+		type sObjectType struct {
+			XMLName xml.Name `xml:"sObjectType"`
+			Name    string   `xml:",chardata"`
+		}
+		var body []sObjectType
+		for _, sobject := range sobjects[start:minInt(start+maxDescribeSObjects, len(sobjects))] {
+			body = append(body, sObjectType{Name: sobject})
+		}
+		var response struct {
+			Results []SObjectDescribe `xml:"Body>describeSObjectsResponse>result"`
+		}
+		if err = partner.partnerExecute("describeSObjects", body, &response); err != nil {
+			return
+		}
+		describes = append(describes, response.Results...)
+	}
+	return
+}
+
+func (partner *ForcePartner) idsExecute(action string, ids []string, response interface{}) error {
+	if len(ids) > maxPartnerRecords {
+		return fmt.Errorf("%w: %d ids, the maximum is %d", PartnerLimitError, len(ids), maxPartnerRecords)
+	}
+	type id struct {
+		XMLName xml.Name `xml:"ids"`
+		Id      string   `xml:",chardata"`
+	}
+	body := make([]id, len(ids))
+	for i := range ids {
+		body[i].Id = ids[i]
+	}
+	return partner.partnerExecute(action, body, response)
+}
+
+// partnerExecute calls action of the partner API with request as its arguments, see
+// SoapEnvelope, and decodes the response envelope into response
+func (partner *ForcePartner) partnerExecute(action string, request interface{}, response interface{}) error {
+	body, err := partner.partnerSoapExecute(action, request, false)
+	if err != nil {
+		return err
+	}
+	if err = xml.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("Error decoding %s response: %w", action, err)
+	}
+	return nil
+}
+
+func (partner *ForcePartner) partnerSoapExecute(action string, request interface{}, refreshed bool) (response []byte, err error) {
+	url := fmt.Sprintf("%s/services/Soap/u/%s", partner.Force.Credentials.InstanceUrl, apiVersionNumber)
+	soap := NewSoap(url, partnerNamespace, partner.Force.Credentials.AccessToken)
+	soap.Headers = partner.Headers
+	response, err = soap.ExecuteRequest(action, request)
+	if err == SessionExpiredError && !refreshed {
+		if e := partner.Force.RefreshSession(); e != nil {
+			return nil, e
+		}
+		return partner.partnerSoapExecute(action, request, true)
+	}
+	return
+}
+
+// partnerSObject writes a record as a partner API sObject: type, Id and fieldsToNull in the
+// sobject namespace, null values as fieldsToNull and the other fields in sorted order
+type partnerSObject struct {
+	SObject string
+	Record  ForceRecord
+}
+
+func (s partnerSObject) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	sobjectElement := func(name, value string) error {
+		return e.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: partnerSObjectNamespace}}})
+	}
+	if err := sobjectElement("type", s.SObject); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(s.Record))
+	for name := range s.Record {
+		if name != "attributes" && name != "Id" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if s.Record[name] == nil {
+			if err := sobjectElement("fieldsToNull", name); err != nil {
+				return err
+			}
+		}
+	}
+	if id, ok := s.Record["Id"].(string); ok {
+		if err := sobjectElement("Id", id); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		value := s.Record[name]
+		if value == nil {
+			continue
+		}
+		if err := e.EncodeElement(partnerValue(value), xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// partnerValue formats a field value as the partner API expects it
+func partnerValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(salesforceDateTimeFormat)
+	case Date:
+		return v.Format(soqlDateFormat)
+	case []string:
+		return strings.Join(v, ";")
+	}
+
+	// numbers are written in full, fmt would write 1000000.0 as 1e+06
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	}
+
+	if v, ok := value.(fmt.Stringer); ok {
+		return v.String()
+	}
+	var buf bytes.Buffer
+	fmt.Fprint(&buf, value)
+	return buf.String()
+}
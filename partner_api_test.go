@@ -0,0 +1,138 @@
+package gforce
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartnerHeaders(t *testing.T) {
+	var request string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request = string(body)
+		fmt.Fprint(w, `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns="urn:partner.soap.sforce.com"><soapenv:Body>`+
+			`<undeleteResponse><result><id>001A</id><success>true</success></result></undeleteResponse></soapenv:Body></soapenv:Envelope>`)
+	})
+	partner := NewForcePartner(f)
+	partner.Headers = []interface{}{AllOrNoneHeader{AllOrNone: true}, CallOptions{Client: "gforce"}}
+
+	results, err := partner.Undelete([]string{"001A"})
+	if err != nil || len(results) != 1 || !results[0].Success {
+		t.Fatalf("undelete '%v' '%v'", results, err)
+	}
+	for _, header := range []string{
+		`<SessionHeader xmlns="urn:partner.soap.sforce.com"><sessionId>token</sessionId></SessionHeader>`,
+		`<AllOrNoneHeader xmlns="urn:partner.soap.sforce.com"><allOrNone>true</allOrNone></AllOrNoneHeader>`,
+		`<CallOptions xmlns="urn:partner.soap.sforce.com"><client>gforce</client></CallOptions>`,
+	} {
+		if !strings.Contains(request, header) {
+			t.Errorf("header '%v' not sent in '%v'", header, request)
+		}
+	}
+}
+
+func TestProcessRequestsMarshal(t *testing.T) {
+	type processItem struct {
+		actions interface{}
+		xml     string
+	}
+	table := []processItem{
+		{
+			actions: []ProcessSubmitRequest{{ObjectId: "006A", Comments: "a < b"}},
+			xml:     `<process xmlns="urn:partner.soap.sforce.com"><actions xsi:type="ProcessSubmitRequest"><comments>a &lt; b</comments><objectId>006A</objectId></actions></process>`,
+		},
+		{
+			actions: []ProcessWorkitemRequest{{Action: "Approve", WorkitemId: "04iA"}, {Action: "Reject", WorkitemId: "04iB"}},
+			xml: `<process xmlns="urn:partner.soap.sforce.com"><actions xsi:type="ProcessWorkitemRequest"><action>Approve</action><workitemId>04iA</workitemId></actions>` +
+				`<actions xsi:type="ProcessWorkitemRequest"><action>Reject</action><workitemId>04iB</workitemId></actions></process>`,
+		},
+	}
+	for i, tt := range table {
+		data, err := SoapEnvelope{Namespace: partnerNamespace, Action: "process", Body: tt.actions}.Marshal()
+		if err != nil {
+			t.Errorf("%d: marshal: %v", i, err)
+			continue
+		}
+		if !strings.Contains(string(data), tt.xml) {
+			t.Errorf("%d: invalid process body in '%s', expected '%s'", i, data, tt.xml)
+		}
+	}
+}
+
+func TestPartnerFault(t *testing.T) {
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:sf="urn:fault.partner.soap.sforce.com"><soapenv:Body><soapenv:Fault>`+
+			`<faultcode>sf:ENTITY_IS_DELETED</faultcode><faultstring>ENTITY_IS_DELETED: entity is deleted</faultstring><detail><sf:UnexpectedErrorFault>`+
+			`<sf:exceptionCode>ENTITY_IS_DELETED</sf:exceptionCode><sf:exceptionMessage>entity is deleted</sf:exceptionMessage></sf:UnexpectedErrorFault></detail>`+
+			`</soapenv:Fault></soapenv:Body></soapenv:Envelope>`)
+	})
+	_, err := NewForcePartner(f).EmptyRecycleBin([]string{"001A"})
+	var fault *ApiFault
+	if !errors.As(err, &fault) || fault.ExceptionCode != "ENTITY_IS_DELETED" {
+		t.Fatalf("expected api fault '%v'", err)
+	}
+	if !errors.Is(err, EntityIsDeleted) {
+		t.Errorf("fault '%v' is not '%v'", err, EntityIsDeleted)
+	}
+}
+
+func TestPartnerValue(t *testing.T) {
+	type valueItem struct {
+		value interface{}
+		text  string
+	}
+	day := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("BRT", -3*60*60))
+	table := []valueItem{
+		{value: "Acme", text: "Acme"},
+		{value: 1000000.0, text: "1000000"},
+		{value: 1234567.891, text: "1234567.891"},
+		{value: float32(0.1), text: "0.1"},
+		{value: 42, text: "42"},
+		{value: int64(-7), text: "-7"},
+		{value: uint8(3), text: "3"},
+		{value: true, text: "true"},
+		{value: day, text: "2021-03-04T08:06:07.000Z"},
+		{value: DateOf(day), text: "2021-03-04"},
+		{value: []string{"South", "North"}, text: "South;North"},
+		{value: 90 * time.Second, text: "90000000000"},
+	}
+	for _, tt := range table {
+		if text := partnerValue(tt.value); text != tt.text {
+			t.Errorf("value of '%v': '%v', expected '%v'", tt.value, text, tt.text)
+		}
+	}
+}
+
+func TestPartnerSObjectValues(t *testing.T) {
+	data, err := xml.Marshal(struct {
+		XMLName xml.Name       `xml:"update"`
+		SObject partnerSObject `xml:"sObjects"`
+	}{SObject: partnerSObject{SObject: "Opportunity", Record: ForceRecord{
+		"Id":          "006A",
+		"Amount":      1500000.0,
+		"Probability": 75,
+		"IsPrivate":   false,
+		"CloseDate":   DateOf(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)),
+		"NextStep":    nil,
+		"Description": nil,
+	}}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	expected := `<update><sObjects><type xmlns="urn:sobject.partner.soap.sforce.com">Opportunity</type>` +
+		`<fieldsToNull xmlns="urn:sobject.partner.soap.sforce.com">Description</fieldsToNull>` +
+		`<fieldsToNull xmlns="urn:sobject.partner.soap.sforce.com">NextStep</fieldsToNull>` +
+		`<Id xmlns="urn:sobject.partner.soap.sforce.com">006A</Id>` +
+		`<Amount>1500000</Amount><CloseDate>2021-03-04</CloseDate><IsPrivate>false</IsPrivate><Probability>75</Probability>` +
+		`</sObjects></update>`
+	if string(data) != expected {
+		t.Errorf("invalid sObject\n%s\nexpected\n%s", data, expected)
+	}
+}
@@ -1,20 +1,13 @@
 package gforce
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
-	"os"
-	"strconv"
 	"strings"
-	"time"
 
 	// "bitbucket.org/everymind/evmd-golib/logger"
 
-	uuid "github.com/satori/go.uuid"
 	"github.com/spf13/cast"
 )
 
@@ -29,26 +22,32 @@ const (
 
 const stringNil = ""
 
-// Count func
+// Count func, deleted and archived records are counted
 func (f *Force) Count(sobject string) (result int, err error) {
-	var res ForceQueryResult
+	return f.CountWhere(context.Background(), sobject, nil, WithQueryAll)
+}
 
-	url := fmt.Sprintf("%s/services/data/%s/queryAll?q=SELECT+COUNT()+FROM+%s", f.Credentials.InstanceUrl, apiVersion, sobject)
-	body, err := f.httpGet(url, false)
+// GetIDs func
+func (f *Force) GetIDs(sobject string, pkField string, where map[string]interface{}, limit, offset int) (result []string, totalSize int, err error) {
+	query, err := writeQuery(sobject, []string{pkField}, where, limit, offset)
 	if err != nil {
 		return
 	}
 
-	json.Unmarshal(body, &res)
-	result = res.TotalSize
-
-	return
+	return f.getIDs(query, pkField)
 }
 
-// GetIDs func
-func (f *Force) GetIDs(sobject string, pkField string, where map[string]interface{}, limit, offset int) (result []string, totalSize int, err error) {
-	query := writeQuery(sobject, []string{pkField}, where, limit, offset)
+// GetIDsQuery func
+func (f *Force) GetIDsQuery(q *SOQLQuery, pkField string) (result []string, totalSize int, err error) {
+	query, err := q.Build()
+	if err != nil {
+		return
+	}
 
+	return f.getIDs(query, pkField)
+}
+
+func (f *Force) getIDs(query, pkField string) (result []string, totalSize int, err error) {
 	res, err := f.exec(query, stringNil, enumQueryAll)
 	if err != nil {
 		return
@@ -63,38 +62,52 @@ func (f *Force) GetIDs(sobject string, pkField string, where map[string]interfac
 	return
 }
 
-func (f *Force) GetIDsStream(sobject string, pkField string, where map[string]interface{}, limit, offset int) (files []string, err error) {
-	query := writeQuery(sobject, []string{pkField}, where, limit, offset)
-
-	filename, nextRecordsURL, err := f.execStream(query, stringNil, sobject, enumQueryAll)
+// GetIDsStream writes the pkField of every matching record to sink page by page
+func (f *Force) GetIDsStream(ctx context.Context, sobject string, pkField string, where map[string]interface{}, limit, offset int, sink QuerySink) (pages []QueryPageInfo, err error) {
+	query, err := writeQuery(sobject, []string{pkField}, where, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("f.execStream(): %w", err)
+		return nil, fmt.Errorf("writeQuery(): %w", err)
 	}
-	files = append(files, filename)
-	for len(nextRecordsURL) > 0 {
-		filename, nextRecordsURL, err = f.execStream("", nextRecordsURL, sobject, enumNil)
-		if err != nil {
-			return nil, fmt.Errorf("f.execStream(): %w", err)
-		}
-		files = append(files, filename)
+
+	pages, err = f.QueryStream(ctx, query, sink, WithQueryAll)
+	if err != nil {
+		return pages, fmt.Errorf("f.QueryStream(): %w", err)
 	}
 
 	return
 }
 
-// Select func
+// Select func, customQuery is sent as is and must already be URL encoded (SELECT+Id+FROM+Account),
+// only its spaces are replaced by +. Use SelectQuery for plain SOQL.
 func (f *Force) Select(sobject string, fields []string, where map[string]interface{}, limit, offset int, customQuery string) (results []ForceRecord, totalSize int, err error) {
-
-	var query string
 	if len(customQuery) > 0 {
-		query = customQuery
-	} else {
-		query = writeQuery(sobject, fields, where, limit, offset)
+		path := fmt.Sprintf("/services/data/%s/queryAll?q=%s", apiVersion, strings.ReplaceAll(customQuery, " ", "+"))
+		res, e := f.exec(stringNil, path, enumNil)
+		return res.Records, res.TotalSize, e
 	}
 
-	res, err := f.exec(query, stringNil, enumQueryAll)
+	query, err := writeQuery(sobject, fields, where, limit, offset)
+	if err != nil {
+		return
+	}
+
+	return f.selectRecords(query, enumQueryAll)
+}
+
+// SelectQuery func
+func (f *Force) SelectQuery(q *SOQLQuery) (results []ForceRecord, totalSize int, err error) {
+	query, err := q.Build()
+	if err != nil {
+		return
+	}
+
+	return f.selectRecords(query, enumQueryAll)
+}
+
+func (f *Force) selectRecords(query string, qType queryType) (results []ForceRecord, totalSize int, err error) {
+	res, err := f.exec(query, stringNil, qType)
 	if err != nil {
-		log.Printf("Error f.exec(): %w", err)
+		log.Printf("Error f.exec(): %v", err)
 		return
 	}
 
@@ -120,218 +133,53 @@ func (f *Force) SelectByID(sobject string, fields []string, id string) (result F
 
 // Tooling func
 func (f *Force) Tooling(sobject string, fields []string, where map[string]interface{}, limit, offset int) (results []ForceRecord, totalSize int, err error) {
-	query := writeQuery(sobject, fields, where, limit, offset)
-
-	res, err := f.exec(query, stringNil, enumTooling)
+	query, err := writeQuery(sobject, fields, where, limit, offset)
 	if err != nil {
 		return
 	}
 
-	results = res.Records
-	totalSize = res.TotalSize
-
-	return
+	return f.selectRecords(query, enumTooling)
 }
 
-func (f *Force) exec(query, nextRecordsURL string, qType queryType) (results ForceQueryResult, err error) {
-	var res ForceQueryResult
-	var q string
-
-	switch qType {
-	case enumQuery:
-		q = fmt.Sprintf("/services/data/%s/query?q=%s", apiVersion, query)
-	case enumQueryAll:
-		q = fmt.Sprintf("/services/data/%s/queryAll?q=%s", apiVersion, query)
-	case enumTooling:
-		q = fmt.Sprintf("/services/data/%s/tooling/query?q=%s", apiVersion, query)
-	default:
-		q = fmt.Sprintf("%s", nextRecordsURL)
-	}
-
-	url := fmt.Sprintf("%s%s", f.Credentials.InstanceUrl, q)
-
-	body, err := f.httpGet(url, false)
+// ToolingQuery func
+func (f *Force) ToolingQuery(q *SOQLQuery) (results []ForceRecord, totalSize int, err error) {
+	query, err := q.Build()
 	if err != nil {
-		log.Printf("Error body f.exec(): %w", err)
 		return
 	}
 
-	err = json.Unmarshal(body, &res)
+	return f.selectRecords(query, enumTooling)
+}
+
+func (f *Force) exec(query, nextRecordsURL string, qType queryType) (results ForceQueryResult, err error) {
+	url := fmt.Sprintf("%s%s", f.Credentials.InstanceUrl, queryPath(query, nextRecordsURL, qType))
+
+	results, err = f.queryAllPages(newQueryIterator(context.Background(), f, url, QueryOptions{}))
 	if err != nil {
-		// logger.Debugf("Error on use new json library: %v", err)
-		return
-	}
-	results = res
-
-	if len(res.NextRecordsUrl) > 0 {
-		nextResults, e := f.exec("", res.NextRecordsUrl, enumNil)
-		if e != nil {
-			log.Printf("Error nextResults f.exec(): %w", e)
-			err = e
-		}
-		results.Records = append(results.Records, nextResults.Records...)
+		log.Printf("Error f.exec(): %v", err)
 	}
 
 	return
 }
 
-func (f *Force) execStream(query, nextRecordsURL, object string, qType queryType) (filename string, nextRecords string, err error) {
-	var res ForceQueryResult
-	var q string
+func writeQuery(sobject string, fields []string, where map[string]interface{}, limit, offset int) (string, error) {
+	return NewSOQLQuery(sobject).
+		Select(fields...).
+		Where(whereFromMap(where)...).
+		Limit(limit).
+		Offset(offset).
+		Build()
+}
 
+func queryPath(query, nextRecordsURL string, qType queryType) string {
 	switch qType {
 	case enumQuery:
-		q = fmt.Sprintf("/services/data/%s/query?q=%s", apiVersion, query)
+		return queryOptionsPath(query, QueryOptions{})
 	case enumQueryAll:
-		q = fmt.Sprintf("/services/data/%s/queryAll?q=%s", apiVersion, query)
+		return queryOptionsPath(query, QueryOptions{QueryAll: true})
 	case enumTooling:
-		q = fmt.Sprintf("/services/data/%s/tooling/query?q=%s", apiVersion, query)
+		return queryOptionsPath(query, QueryOptions{IsTooling: true})
 	default:
-		q = nextRecordsURL
+		return nextRecordsURL
 	}
-
-	url := fmt.Sprintf("%s%s", f.Credentials.InstanceUrl, q)
-
-	resp, err := f.httpGetStream(url, false)
-	if err != nil {
-		return "", "", fmt.Errorf("f.httpGetStream(): %w", err)
-	}
-	// logger.Debugf("Exec request to => %v", url)
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", "", fmt.Errorf("ioutil.ReadAll(): %w", err)
-	}
-	defer resp.Body.Close()
-	err = json.Unmarshal(body, &res)
-
-	id := uuid.NewV4()
-	filename = fmt.Sprintf("/tmp/%s_%s.json", object, id.String())
-	out, err := os.Create(filename)
-	if err != nil {
-		return "", "", fmt.Errorf("os.Create(): %w", err)
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, ioutil.NopCloser(bytes.NewReader(body)))
-	if err != nil {
-		return "", "", fmt.Errorf("io.Copy(): %w", err)
-	}
-
-	nextRecords = res.NextRecordsUrl
-
-	return
-}
-
-func writeQuery(sobject string, fields []string, where map[string]interface{}, limit, offset int) string {
-	var query strings.Builder
-
-	query.WriteString("SELECT+")
-	query.WriteString(strings.Join(fields, ","))
-	query.WriteString("+FROM+")
-	query.WriteString(sobject)
-
-	if len(where) > 0 {
-		query.WriteString("+WHERE+")
-
-		addAnd := func(i int, k string) {
-			if i > 0 {
-				query.WriteString("+AND+")
-			}
-			query.WriteString(k)
-		}
-
-		var i int = 0
-		for k, v := range where {
-			switch t := v.(type) {
-			case string:
-				{
-					addAnd(i, k)
-					query.WriteString("='")
-					query.WriteString(strings.ReplaceAll(t, "'", ""))
-					query.WriteString("'")
-				}
-			case []string:
-				{
-					addAnd(i, k)
-					query.WriteString("+IN+(")
-					for index, item := range t {
-						query.WriteString("'")
-						query.WriteString(strings.ReplaceAll(item, "'", ""))
-						query.WriteString("'")
-						if len(t) > 1 && index < len(t)-1 {
-							query.WriteString(",")
-						}
-					}
-					query.WriteString(")")
-				}
-			case bool:
-				{
-					addAnd(i, k)
-					query.WriteString("=")
-					query.WriteString(strconv.FormatBool(t))
-				}
-			case int, int8, int16, int32, int64:
-				{
-					addAnd(i, k)
-					query.WriteString("=")
-					query.WriteString(strconv.FormatInt(t.(int64), 10))
-				}
-			case []int, []int8, []int16, []int32, []int64:
-				{
-					addAnd(i, k)
-					query.WriteString("+IN+(")
-					slice := t.([]int64)
-					for index, item := range slice {
-						query.WriteString(strconv.FormatInt(item, 10))
-						if len(slice) > 1 && index < len(slice)-1 {
-							query.WriteString(",")
-						}
-					}
-					query.WriteString(")")
-				}
-			case float32, float64:
-				{
-					addAnd(i, k)
-					query.WriteString("=")
-					query.WriteString(strconv.FormatFloat(t.(float64), 'f', -1, 64))
-				}
-			case []float32, []float64:
-				{
-					addAnd(i, k)
-					query.WriteString("+IN+(")
-					slice := t.([]float64)
-					for index, item := range slice {
-						query.WriteString(strconv.FormatFloat(item, 'f', -1, 64))
-						if len(slice) > 1 && index < len(slice)-1 {
-							query.WriteString(",")
-						}
-					}
-					query.WriteString(")")
-				}
-			case time.Time:
-				{
-					addAnd(i, k)
-					query.WriteString("='")
-					query.WriteString(t.Format(time.RFC3339))
-					query.WriteString("'")
-				}
-			default:
-			}
-
-			i++
-		}
-	}
-
-	if limit > 0 {
-		query.WriteString("+LIMIT+")
-		query.WriteString(strconv.Itoa(limit))
-	}
-
-	if offset > 0 {
-		query.WriteString("+OFFSET+")
-		query.WriteString(strconv.Itoa(offset))
-	}
-
-	return query.String()
 }
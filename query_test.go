@@ -0,0 +1,57 @@
+package gforce
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestForce(t *testing.T, handler http.HandlerFunc) *Force {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Force{Credentials: &ForceSession{InstanceUrl: server.URL, AccessToken: "token"}}
+}
+
+func TestSelectQueryEncoding(t *testing.T) {
+	var rawQuery string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		fmt.Fprint(w, `{"done":true,"totalSize":1,"records":[{"Id":"001000000000001AAA"}]}`)
+	})
+
+	type selectItem struct {
+		customQuery string
+		query       *SOQLQuery
+		rawQuery    string
+	}
+	table := []selectItem{
+		{
+			customQuery: "SELECT+Id+FROM+Account+WHERE+Name='A+B'",
+			rawQuery:    "q=SELECT+Id+FROM+Account+WHERE+Name='A+B'",
+		},
+		{
+			customQuery: "SELECT Id FROM Account",
+			rawQuery:    "q=SELECT+Id+FROM+Account",
+		},
+		{
+			query:    NewSOQLQuery("Account").Select("Id").Where(Eq("Name", "A+B")),
+			rawQuery: "q=SELECT+Id+FROM+Account+WHERE+Name+%3D+%27A%2BB%27",
+		},
+	}
+	for _, tt := range table {
+		var records []ForceRecord
+		var err error
+		if tt.query != nil {
+			records, _, err = f.SelectQuery(tt.query)
+		} else {
+			records, _, err = f.Select("", nil, nil, 0, 0, tt.customQuery)
+		}
+		if err != nil || len(records) != 1 {
+			t.Errorf("select '%v' '%v'", records, err)
+		}
+		if rawQuery != tt.rawQuery {
+			t.Errorf("invalid query string '%v', expected '%v'", rawQuery, tt.rawQuery)
+		}
+	}
+}
@@ -43,7 +43,7 @@ func (f *Force) refreshOauth() (err error) {
 		if err != nil {
 			// logger.Errorf("Error on Refresh Token Request: %w", err)
 			// log.Println(fmt.Errorf("Error on Refresh Token Request: %w", err))
-			log.Printf("Error on Refresh Token Request: %w", err)
+			log.Printf("Error on Refresh Token Request: %v", err)
 			continue
 		}
 		defer res.Body.Close()
@@ -60,7 +60,7 @@ func (f *Force) refreshOauth() (err error) {
 		}
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			log.Printf("Error on Parse Token Body: %w", err)
+			log.Printf("Error on Parse Token Body: %v", err)
 			// logger.Errorf("Error on Parse Token Body: %w", err)
 			// log.Println(fmt.Errorf("Error on Parse Token Body: %w", err))
 		}
@@ -85,7 +85,7 @@ func (f *Force) RefreshSession() (err error) {
 		err = errors.New("Unable to refresh")
 	}
 
-	log.Printf("Return of refreshOAuth: %w", err)
+	log.Printf("Return of refreshOAuth: %v", err)
 
 	if err == nil {
 		f.Credentials.SessionRefreshed = true
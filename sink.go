@@ -0,0 +1,275 @@
+package gforce
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+var SinkClosedError = errors.New("Sink closed")
+
+// QueryPage is a page of query results handed to a QuerySink
+type QueryPage struct {
+	Index          int
+	Records        []ForceRecord
+	TotalSize      int
+	NextRecordsURL string
+}
+
+// QueryPageInfo reports what happened to a page written by QueryStream
+type QueryPageInfo struct {
+	Index          int
+	Records        int
+	TotalSize      int
+	NextRecordsURL string
+	Location       string
+}
+
+// QuerySink receives the pages of a streamed query. WritePage returns where the page was
+// written (file or object name) or an empty location when it does not apply.
+// ParallelExtract calls WritePage concurrently; the sinks of this package serialize their writes.
+type QuerySink interface {
+	WritePage(ctx context.Context, page QueryPage) (location string, err error)
+	Close() error
+}
+
+// ObjectStore creates objects in a cloud bucket, see NewGCSObjectStore
+type ObjectStore interface {
+	NewWriter(ctx context.Context, name string) (io.WriteCloser, error)
+}
+
+// RotateOptions controls when DirectorySink and ObjectStoreSink start a new file
+type RotateOptions struct {
+	// Prefix of the generated names, defaults to a random UUID
+	Prefix string
+	// MaxRecords per file, 0 writes a file per page
+	MaxRecords int
+}
+
+// QueryStream sends every page of query to sink and closes sink at the end
+func (f *Force) QueryStream(ctx context.Context, query string, sink QuerySink, options ...func(*QueryOptions)) (pages []QueryPageInfo, err error) {
+	return f.streamPages(ctx, f.NewQueryIterator(ctx, query, options...), sink)
+}
+
+func (f *Force) streamPages(ctx context.Context, it *QueryIterator, sink QuerySink) (pages []QueryPageInfo, err error) {
+	defer it.Close()
+	defer func() {
+		if e := sink.Close(); e != nil && err == nil {
+			err = fmt.Errorf("sink.Close(): %w", e)
+		}
+	}()
+
+	for index := 0; ; index++ {
+		records, ok := it.Page()
+		if !ok {
+			break
+		}
+		page := QueryPage{
+			Index:          index,
+			Records:        records,
+			TotalSize:      it.TotalSize(),
+			NextRecordsURL: it.NextRecordsURL(),
+		}
+		location, e := sink.WritePage(ctx, page)
+		if e != nil {
+			return pages, fmt.Errorf("sink.WritePage(%d): %w", index, e)
+		}
+		pages = append(pages, QueryPageInfo{
+			Index:          index,
+			Records:        len(records),
+			TotalSize:      page.TotalSize,
+			NextRecordsURL: page.NextRecordsURL,
+			Location:       location,
+		})
+	}
+
+	err = it.Err()
+	return
+}
+
+// WriterSink writes records to an io.Writer as newline delimited JSON
+type WriterSink struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	bw := bufio.NewWriter(w)
+	return &WriterSink{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (s *WriterSink) WritePage(ctx context.Context, page QueryPage) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range page.Records {
+		if err := s.enc.Encode(record); err != nil {
+			return "", err
+		}
+	}
+	return "", s.w.Flush()
+}
+
+func (s *WriterSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Flush()
+}
+
+// rotatingSink writes NDJSON files through open, starting a new one every MaxRecords records
+type rotatingSink struct {
+	mu      sync.Mutex
+	open    func(ctx context.Context, name string) (io.WriteCloser, error)
+	options RotateOptions
+	current io.WriteCloser
+	buf     *bufio.Writer
+	name    string
+	count   int
+	files   int
+	closed  bool
+}
+
+func newRotatingSink(options RotateOptions, open func(ctx context.Context, name string) (io.WriteCloser, error)) *rotatingSink {
+	if options.Prefix == "" {
+		options.Prefix = uuid.NewV4().String()
+	}
+	return &rotatingSink{open: open, options: options}
+}
+
+func (s *rotatingSink) WritePage(ctx context.Context, page QueryPage) (location string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return "", SinkClosedError
+	}
+
+	if s.options.MaxRecords <= 0 {
+		if err = s.rotate(ctx); err != nil {
+			return
+		}
+	}
+
+	for _, record := range page.Records {
+		if s.current == nil || (s.options.MaxRecords > 0 && s.count >= s.options.MaxRecords) {
+			if err = s.rotate(ctx); err != nil {
+				return
+			}
+		}
+		var b []byte
+		if b, err = json.Marshal(record); err != nil {
+			return
+		}
+		b = append(b, '\n')
+		if _, err = s.buf.Write(b); err != nil {
+			return
+		}
+		s.count++
+	}
+
+	if s.current != nil {
+		location = s.name
+		err = s.buf.Flush()
+	}
+	return
+}
+
+func (s *rotatingSink) rotate(ctx context.Context) error {
+	if err := s.closeCurrent(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%05d.ndjson", s.options.Prefix, s.files)
+	w, err := s.open(ctx, name)
+	if err != nil {
+		return err
+	}
+	s.current = w
+	s.buf = bufio.NewWriter(w)
+	s.name = name
+	s.count = 0
+	s.files++
+	return nil
+}
+
+func (s *rotatingSink) closeCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+	current := s.current
+	s.current = nil
+	if err := s.buf.Flush(); err != nil {
+		current.Close()
+		return err
+	}
+	return current.Close()
+}
+
+func (s *rotatingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.closeCurrent()
+}
+
+// DirectorySink writes NDJSON files named <prefix>_<n>.ndjson into a directory
+type DirectorySink struct {
+	*rotatingSink
+	Dir string
+}
+
+func NewDirectorySink(dir string, options RotateOptions) (*DirectorySink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	sink := &DirectorySink{Dir: dir}
+	sink.rotatingSink = newRotatingSink(options, func(ctx context.Context, name string) (io.WriteCloser, error) {
+		return os.Create(filepath.Join(dir, name))
+	})
+	return sink, nil
+}
+
+// WritePage returns the full path of the file holding the last record of the page
+func (s *DirectorySink) WritePage(ctx context.Context, page QueryPage) (string, error) {
+	name, err := s.rotatingSink.WritePage(ctx, page)
+	if name == "" {
+		return name, err
+	}
+	return filepath.Join(s.Dir, name), err
+}
+
+// ObjectStoreSink writes NDJSON objects named <prefix>_<n>.ndjson into an ObjectStore
+type ObjectStoreSink struct {
+	*rotatingSink
+}
+
+func NewObjectStoreSink(store ObjectStore, options RotateOptions) *ObjectStoreSink {
+	return &ObjectStoreSink{newRotatingSink(options, store.NewWriter)}
+}
+
+// GCSObjectStore stores objects in a Google Cloud Storage bucket under an optional folder
+type GCSObjectStore struct {
+	Bucket *storage.BucketHandle
+	Folder string
+}
+
+func NewGCSObjectStore(client *storage.Client, bucket, folder string) *GCSObjectStore {
+	return &GCSObjectStore{Bucket: client.Bucket(bucket), Folder: folder}
+}
+
+func (s *GCSObjectStore) NewWriter(ctx context.Context, name string) (io.WriteCloser, error) {
+	if s.Folder != "" {
+		name = s.Folder + "/" + name
+	}
+	w := s.Bucket.Object(name).NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+	return w, nil
+}
@@ -0,0 +1,95 @@
+package gforce
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDirectorySinkRotation(t *testing.T) {
+	f := newTestForce(t, serveIteratorPages)
+
+	type rotationItem struct {
+		maxRecords int
+		files      map[string]string
+		locations  []string
+	}
+	table := []rotationItem{
+		{
+			maxRecords: 0,
+			files: map[string]string{
+				"run_00000.ndjson": "1,2",
+				"run_00001.ndjson": "3,4",
+				"run_00002.ndjson": "5",
+			},
+			locations: []string{"run_00000.ndjson", "run_00001.ndjson", "run_00002.ndjson"},
+		},
+		{
+			maxRecords: 3,
+			files: map[string]string{
+				"run_00000.ndjson": "1,2,3",
+				"run_00001.ndjson": "4,5",
+			},
+			locations: []string{"run_00000.ndjson", "run_00001.ndjson", "run_00001.ndjson"},
+		},
+		{
+			maxRecords: 10,
+			files: map[string]string{
+				"run_00000.ndjson": "1,2,3,4,5",
+			},
+			locations: []string{"run_00000.ndjson", "run_00000.ndjson", "run_00000.ndjson"},
+		},
+	}
+	for _, tt := range table {
+		dir := t.TempDir()
+		sink, err := NewDirectorySink(dir, RotateOptions{Prefix: "run", MaxRecords: tt.maxRecords})
+		if err != nil {
+			t.Fatalf("NewDirectorySink error '%v'", err)
+		}
+		pages, err := f.QueryStream(context.Background(), "SELECT Id FROM Account", sink)
+		if err != nil {
+			t.Fatalf("max %v: QueryStream error '%v'", tt.maxRecords, err)
+		}
+
+		var locations []string
+		for _, page := range pages {
+			locations = append(locations, strings.TrimPrefix(page.Location, dir+string(filepath.Separator)))
+		}
+		if !reflect.DeepEqual(locations, tt.locations) {
+			t.Errorf("max %v: locations '%v', expected '%v'", tt.maxRecords, locations, tt.locations)
+		}
+		if files := readSinkFiles(t, dir); !reflect.DeepEqual(files, tt.files) {
+			t.Errorf("max %v: files '%v', expected '%v'", tt.maxRecords, files, tt.files)
+		}
+
+		if _, err = sink.WritePage(context.Background(), QueryPage{Records: []ForceRecord{{"Id": "6"}}}); !errors.Is(err, SinkClosedError) {
+			t.Errorf("max %v: write after close error '%v', expected '%v'", tt.maxRecords, err, SinkClosedError)
+		}
+	}
+}
+
+// readSinkFiles returns the comma separated record ids of each NDJSON file in dir
+func readSinkFiles(t *testing.T, dir string) map[string]string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		content, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			record := decodeTestRecord(t, line)
+			ids = append(ids, record["Id"].(string))
+		}
+		files[entry.Name()] = strings.Join(ids, ",")
+	}
+	return files
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"strings"
 )
 
 type SoapError struct {
@@ -17,8 +16,10 @@ type SoapError struct {
 type Soap struct {
 	AccessToken string
 	Endpoint    string
-	Header      string
-	Namespace   string
+	// Header is raw XML added to the envelope header, Headers are encoded like SoapEnvelope.Headers
+	Header    string
+	Headers   []interface{}
+	Namespace string
 }
 
 func NewSoap(endpoint, namespace, accessToken string) (s *Soap) {
@@ -76,30 +77,27 @@ func (s *Soap) ExecuteLogin(username, password string) (response []byte, err err
 
 }
 
+// Execute calls action with query, a raw XML fragment, as its arguments. Prefer
+// ExecuteRequest, which escapes the values.
 func (s *Soap) Execute(action, query string) (response []byte, err error) {
-	soap := `
-		<env:Envelope xmlns:xsd="http://www.w3.org/2001/XMLSchema" 
-		xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" 
-		xmlns:env="http://schemas.xmlsoap.org/soap/envelope/" 
-		xmlns:cmd="%s" 
-		xmlns:apex="http://soap.sforce.com/2006/08/apex">
-			<env:Header>
-				<cmd:SessionHeader>
-					<cmd:sessionId>%s</cmd:sessionId>
-				</cmd:SessionHeader>
-				%s
-			</env:Header>
-			<env:Body>
-				<%s xmlns="%s">
-					%s
-				</%s>
-			</env:Body>
-		</env:Envelope>
-	`
-	rbody := fmt.Sprintf(soap, s.Namespace,
-		s.AccessToken, s.Header, action, s.Namespace, query, action)
-	//fmt.Println(rbody)
-	req, err := httpRequest("POST", s.Endpoint, strings.NewReader(rbody))
+	return s.ExecuteRequest(action, soapRawXML{Inner: query})
+}
+
+// ExecuteRequest calls action with the XML encoding of body as its arguments, see SoapEnvelope.
+// A fault is returned as an *ApiFault.
+func (s *Soap) ExecuteRequest(action string, body interface{}) (response []byte, err error) {
+	envelope := SoapEnvelope{
+		Namespace: s.Namespace,
+		Headers:   append([]interface{}{SessionHeader{SessionId: s.AccessToken}}, s.Headers...),
+		RawHeader: s.Header,
+		Action:    action,
+		Body:      body,
+	}
+	rbody, err := envelope.Marshal()
+	if err != nil {
+		return
+	}
+	req, err := httpRequest("POST", s.Endpoint, bytes.NewReader(rbody))
 	if err != nil {
 		return
 	}
@@ -118,25 +116,16 @@ func (s *Soap) Execute(action, query string) (response []byte, err error) {
 	if err != nil {
 		return
 	}
-	if isSoapInvalidSessionError(response) {
+	// callers compare with SessionExpiredError to refresh the session and retry
+	if err = processError(response); errors.Is(err, SessionExpiredError) {
 		err = SessionExpiredError
-		return
 	}
-	err = processError(response)
 	return
 }
 
-func isSoapInvalidSessionError(body []byte) bool {
-	var soapError SoapError
-	xml.Unmarshal(body, &soapError)
-	return soapError.FaultCode == "sf:INVALID_SESSION_ID"
-}
-
 func processError(body []byte) (err error) {
-	var soapError SoapError
-	xml.Unmarshal(body, &soapError)
-	if soapError.FaultCode != "" {
-		return errors.New(soapError.FaultString)
+	if fault := DecodeSoapFault(body); fault != nil {
+		return fault
 	}
 	return
 }
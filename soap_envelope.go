@@ -0,0 +1,204 @@
+package gforce
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	soapEnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	apexNamespace         = "http://soap.sforce.com/2006/08/apex"
+)
+
+type SessionHeader struct {
+	SessionId string `xml:"sessionId"`
+}
+
+// CallOptions identifies the client, and the namespace of unqualified names in managed packages
+type CallOptions struct {
+	Client           string `xml:"client,omitempty"`
+	DefaultNamespace string `xml:"defaultNamespace,omitempty"`
+}
+
+// AllOrNoneHeader rolls back every record of a call when one fails
+type AllOrNoneHeader struct {
+	AllOrNone bool `xml:"allOrNone"`
+}
+
+// DebuggingHeader returns the debug log of an Apex call, DebugLevel is NONE, DEBUGONLY, DB,
+// PROFILING, CALLOUT or DETAIL
+type DebuggingHeader struct {
+	Categories []LogInfo `xml:"categories,omitempty"`
+	DebugLevel string    `xml:"debugLevel,omitempty"`
+}
+
+type LogInfo struct {
+	Category string `xml:"category"`
+	Level    string `xml:"level"`
+}
+
+// SoapEnvelope builds a request envelope with encoding/xml, so that values holding < or &
+// are escaped.
+//
+// Headers are structs (SessionHeader, CallOptions, AllOrNoneHeader, DebuggingHeader...) written
+// as elements named after their type in Namespace. RawHeader is XML added after them as is.
+// The Action element, in Namespace, holds Body: the fields of a struct, or the elements of any
+// other value such as a slice of structs with an XMLName.
+//
+//	envelope := SoapEnvelope{
+//		Namespace: "urn:partner.soap.sforce.com",
+//		Headers:   []interface{}{SessionHeader{SessionId: token}, AllOrNoneHeader{AllOrNone: true}},
+//		Action:    "undelete",
+//		Body:      struct{ Ids []string `xml:"ids"` }{ids},
+//	}
+type SoapEnvelope struct {
+	Namespace string
+	Headers   []interface{}
+	RawHeader string
+	Action    string
+	Body      interface{}
+}
+
+type soapEnvelope struct {
+	XMLName xml.Name    `xml:"env:Envelope"`
+	EnvNS   string      `xml:"xmlns:env,attr"`
+	XsdNS   string      `xml:"xmlns:xsd,attr"`
+	XsiNS   string      `xml:"xmlns:xsi,attr"`
+	CmdNS   string      `xml:"xmlns:cmd,attr"`
+	ApexNS  string      `xml:"xmlns:apex,attr"`
+	Header  soapHeaders `xml:"env:Header"`
+	Body    soapAction  `xml:"env:Body>action"`
+}
+
+type soapHeaders struct {
+	Headers []soapHeader
+	Raw     string `xml:",innerxml"`
+}
+
+type soapHeader struct {
+	namespace string
+	value     interface{}
+}
+
+type soapAction struct {
+	name xml.Name
+	body interface{}
+}
+
+// soapRawXML is a body given as an XML fragment
+type soapRawXML struct {
+	Inner string `xml:",innerxml"`
+}
+
+// Marshal returns the XML of the envelope
+func (e SoapEnvelope) Marshal() ([]byte, error) {
+	if e.Action == "" {
+		return nil, fmt.Errorf("SoapEnvelope has no Action")
+	}
+	headers := soapHeaders{Headers: make([]soapHeader, len(e.Headers)), Raw: e.RawHeader}
+	for i, header := range e.Headers {
+		headers.Headers[i] = soapHeader{namespace: e.Namespace, value: header}
+	}
+	var buf bytes.Buffer
+	err := xml.NewEncoder(&buf).Encode(soapEnvelope{
+		EnvNS:  soapEnvelopeNamespace,
+		XsdNS:  "http://www.w3.org/2001/XMLSchema",
+		XsiNS:  "http://www.w3.org/2001/XMLSchema-instance",
+		CmdNS:  e.Namespace,
+		ApexNS: apexNamespace,
+		Header: headers,
+		Body:   soapAction{name: xml.Name{Space: e.Namespace, Local: e.Action}, body: e.Body},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (h soapHeader) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	t := reflect.TypeOf(h.value)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() == "" {
+		return fmt.Errorf("SOAP header %T has no type name", h.value)
+	}
+	return e.EncodeElement(h.value, xml.StartElement{Name: xml.Name{Space: h.namespace, Local: t.Name()}})
+}
+
+func (a soapAction) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: a.name}
+	if a.body != nil && reflect.Indirect(reflect.ValueOf(a.body)).Kind() == reflect.Struct {
+		return e.EncodeElement(a.body, start)
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if a.body != nil {
+		if err := e.Encode(a.body); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ApiFault is a SOAP fault. ExceptionCode comes from the fault detail (INVALID_ID_FIELD,
+// INVALID_SESSION_ID...), Row and Column locate a MALFORMED_QUERY. It unwraps to the library
+// error matching ExceptionCode, like SubrequestError.
+type ApiFault struct {
+	FaultCode        string
+	FaultString      string
+	FaultType        string
+	ExceptionCode    string
+	ExceptionMessage string
+	Row              int
+	Column           int
+}
+
+// DecodeSoapFault returns the fault of a response envelope, nil when there is none
+func DecodeSoapFault(body []byte) *ApiFault {
+	var envelope struct {
+		FaultCode   string `xml:"Body>Fault>faultcode"`
+		FaultString string `xml:"Body>Fault>faultstring"`
+		Detail      struct {
+			Fault struct {
+				XMLName          xml.Name
+				ExceptionCode    string `xml:"exceptionCode"`
+				ExceptionMessage string `xml:"exceptionMessage"`
+				Row              int    `xml:"row"`
+				Column           int    `xml:"column"`
+			} `xml:",any"`
+		} `xml:"Body>Fault>detail"`
+	}
+	if xml.Unmarshal(body, &envelope) != nil || envelope.FaultCode == "" {
+		return nil
+	}
+	detail := envelope.Detail.Fault
+	fault := &ApiFault{
+		FaultCode:        envelope.FaultCode,
+		FaultString:      envelope.FaultString,
+		FaultType:        detail.XMLName.Local,
+		ExceptionCode:    detail.ExceptionCode,
+		ExceptionMessage: detail.ExceptionMessage,
+		Row:              detail.Row,
+		Column:           detail.Column,
+	}
+	if fault.ExceptionCode == "" {
+		fault.ExceptionCode = fault.FaultCode[strings.LastIndex(fault.FaultCode, ":")+1:]
+	}
+	if fault.ExceptionMessage == "" {
+		fault.ExceptionMessage = fault.FaultString
+	}
+	return fault
+}
+
+func (f *ApiFault) Error() string {
+	return f.FaultString
+}
+
+func (f *ApiFault) Unwrap() error {
+	return mapForceError(ForceError{Message: f.ExceptionMessage, ErrorCode: f.ExceptionCode})
+}
@@ -0,0 +1,152 @@
+package gforce
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func TestSoapEnvelopeMarshal(t *testing.T) {
+	type marshalItem struct {
+		envelope SoapEnvelope
+		xml      string
+		err      bool
+	}
+	prefix := `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:cmd="urn:partner.soap.sforce.com" xmlns:apex="http://soap.sforce.com/2006/08/apex">`
+	table := []marshalItem{
+		{
+			envelope: SoapEnvelope{
+				Namespace: "urn:partner.soap.sforce.com",
+				Headers:   []interface{}{SessionHeader{SessionId: "00D!a<b>&c"}, &AllOrNoneHeader{AllOrNone: true}},
+				Action:    "undelete",
+				Body: struct {
+					Ids []string `xml:"ids"`
+				}{[]string{"001A", `"&'`}},
+			},
+			xml: prefix + `<env:Header><SessionHeader xmlns="urn:partner.soap.sforce.com"><sessionId>00D!a&lt;b&gt;&amp;c</sessionId></SessionHeader>` +
+				`<AllOrNoneHeader xmlns="urn:partner.soap.sforce.com"><allOrNone>true</allOrNone></AllOrNoneHeader></env:Header>` +
+				`<env:Body><undelete xmlns="urn:partner.soap.sforce.com"><ids>001A</ids><ids>&#34;&amp;&#39;</ids></undelete></env:Body></env:Envelope>`,
+		},
+		{
+			envelope: SoapEnvelope{
+				Namespace: "http://soap.sforce.com/2006/08/apex",
+				RawHeader: `<cmd:SessionHeader><cmd:sessionId>token</cmd:sessionId></cmd:SessionHeader>`,
+				Action:    "executeAnonymous",
+				Body:      soapRawXML{Inner: `<String>System.debug(1);</String>`},
+			},
+			xml: `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" ` +
+				`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:cmd="http://soap.sforce.com/2006/08/apex" xmlns:apex="http://soap.sforce.com/2006/08/apex">` +
+				`<env:Header><cmd:SessionHeader><cmd:sessionId>token</cmd:sessionId></cmd:SessionHeader></env:Header>` +
+				`<env:Body><executeAnonymous xmlns="http://soap.sforce.com/2006/08/apex"><String>System.debug(1);</String></executeAnonymous></env:Body></env:Envelope>`,
+		},
+		{
+			envelope: SoapEnvelope{Namespace: "urn:partner.soap.sforce.com"},
+			err:      true,
+		},
+		{
+			envelope: SoapEnvelope{Namespace: "urn:partner.soap.sforce.com", Headers: []interface{}{map[string]string{}}, Action: "logout"},
+			err:      true,
+		},
+	}
+	for i, tt := range table {
+		data, err := tt.envelope.Marshal()
+		if (err != nil) != tt.err {
+			t.Errorf("%d: marshal error '%v'", i, err)
+			continue
+		}
+		if string(data) != tt.xml {
+			t.Errorf("%d: invalid envelope\n%s\nexpected\n%s", i, data, tt.xml)
+		}
+	}
+}
+
+func TestPartnerSObjectMarshal(t *testing.T) {
+	data, err := xml.Marshal(struct {
+		XMLName xml.Name       `xml:"create"`
+		SObject partnerSObject `xml:"sObjects"`
+	}{SObject: partnerSObject{SObject: "Account", Record: ForceRecord{
+		"attributes":  map[string]interface{}{"type": "Account"},
+		"Id":          "001A",
+		"Name":        "Smith & Sons <Ltd>",
+		"Description": nil,
+		"Site":        nil,
+	}}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	expected := `<create><sObjects><type xmlns="urn:sobject.partner.soap.sforce.com">Account</type>` +
+		`<fieldsToNull xmlns="urn:sobject.partner.soap.sforce.com">Description</fieldsToNull>` +
+		`<fieldsToNull xmlns="urn:sobject.partner.soap.sforce.com">Site</fieldsToNull>` +
+		`<Id xmlns="urn:sobject.partner.soap.sforce.com">001A</Id>` +
+		`<Name>Smith &amp; Sons &lt;Ltd&gt;</Name></sObjects></create>`
+	if string(data) != expected {
+		t.Errorf("invalid sObject\n%s\nexpected\n%s", data, expected)
+	}
+}
+
+func TestDecodeSoapFault(t *testing.T) {
+	type faultItem struct {
+		body  string
+		fault *ApiFault
+		is    error
+	}
+	table := []faultItem{
+		{
+			body: `<?xml version="1.0" encoding="UTF-8"?><soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:sf="urn:fault.partner.soap.sforce.com">` +
+				`<soapenv:Body><soapenv:Fault><faultcode>sf:INVALID_SESSION_ID</faultcode><faultstring>INVALID_SESSION_ID: Invalid Session ID found in SessionHeader</faultstring>` +
+				`<detail><sf:UnexpectedErrorFault><sf:exceptionCode>INVALID_SESSION_ID</sf:exceptionCode><sf:exceptionMessage>Invalid Session ID found in SessionHeader</sf:exceptionMessage></sf:UnexpectedErrorFault></detail>` +
+				`</soapenv:Fault></soapenv:Body></soapenv:Envelope>`,
+			fault: &ApiFault{
+				FaultCode:        "sf:INVALID_SESSION_ID",
+				FaultString:      "INVALID_SESSION_ID: Invalid Session ID found in SessionHeader",
+				FaultType:        "UnexpectedErrorFault",
+				ExceptionCode:    "INVALID_SESSION_ID",
+				ExceptionMessage: "Invalid Session ID found in SessionHeader",
+			},
+			is: SessionExpiredError,
+		},
+		{
+			body: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><soapenv:Fault>` +
+				`<faultcode>sf:MALFORMED_QUERY</faultcode><faultstring>unexpected token: FORM</faultstring>` +
+				`<detail><sf:MalformedQueryFault xmlns:sf="urn:fault.partner.soap.sforce.com"><sf:exceptionCode>MALFORMED_QUERY</sf:exceptionCode>` +
+				`<sf:exceptionMessage>unexpected token: FORM</sf:exceptionMessage><sf:row>1</sf:row><sf:column>10</sf:column></sf:MalformedQueryFault></detail>` +
+				`</soapenv:Fault></soapenv:Body></soapenv:Envelope>`,
+			fault: &ApiFault{
+				FaultCode:        "sf:MALFORMED_QUERY",
+				FaultString:      "unexpected token: FORM",
+				FaultType:        "MalformedQueryFault",
+				ExceptionCode:    "MALFORMED_QUERY",
+				ExceptionMessage: "unexpected token: FORM",
+				Row:              1,
+				Column:           10,
+			},
+		},
+		{
+			body: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><soapenv:Fault>` +
+				`<faultcode>soapenv:Client</faultcode><faultstring>Element {}item invalid at this location</faultstring></soapenv:Fault></soapenv:Body></soapenv:Envelope>`,
+			fault: &ApiFault{
+				FaultCode:        "soapenv:Client",
+				FaultString:      "Element {}item invalid at this location",
+				ExceptionCode:    "Client",
+				ExceptionMessage: "Element {}item invalid at this location",
+			},
+		},
+		{
+			body: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><undeleteResponse/></soapenv:Body></soapenv:Envelope>`,
+		},
+		{
+			body: `not xml`,
+		},
+	}
+	for i, tt := range table {
+		fault := DecodeSoapFault([]byte(tt.body))
+		if (fault == nil) != (tt.fault == nil) || (fault != nil && *fault != *tt.fault) {
+			t.Errorf("%d: invalid fault '%+v', expected '%+v'", i, fault, tt.fault)
+			continue
+		}
+		if tt.is != nil && !errors.Is(fault, tt.is) {
+			t.Errorf("%d: fault '%v' is not '%v'", i, fault, tt.is)
+		}
+	}
+}
@@ -0,0 +1,605 @@
+package gforce
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SOQL sort directions and null ordering
+const (
+	Asc  SOQLOrder = "ASC"
+	Desc SOQLOrder = "DESC"
+
+	NullsFirst SOQLNulls = "NULLS FIRST"
+	NullsLast  SOQLNulls = "NULLS LAST"
+)
+
+// SOQL date literals, written unquoted in the query
+const (
+	Yesterday      DateLiteral = "YESTERDAY"
+	Today          DateLiteral = "TODAY"
+	Tomorrow       DateLiteral = "TOMORROW"
+	LastWeek       DateLiteral = "LAST_WEEK"
+	ThisWeek       DateLiteral = "THIS_WEEK"
+	NextWeek       DateLiteral = "NEXT_WEEK"
+	LastMonth      DateLiteral = "LAST_MONTH"
+	ThisMonth      DateLiteral = "THIS_MONTH"
+	NextMonth      DateLiteral = "NEXT_MONTH"
+	Last90Days     DateLiteral = "LAST_90_DAYS"
+	Next90Days     DateLiteral = "NEXT_90_DAYS"
+	LastQuarter    DateLiteral = "LAST_QUARTER"
+	ThisQuarter    DateLiteral = "THIS_QUARTER"
+	NextQuarter    DateLiteral = "NEXT_QUARTER"
+	LastYear       DateLiteral = "LAST_YEAR"
+	ThisYear       DateLiteral = "THIS_YEAR"
+	NextYear       DateLiteral = "NEXT_YEAR"
+	LastFiscalYear DateLiteral = "LAST_FISCAL_YEAR"
+	ThisFiscalYear DateLiteral = "THIS_FISCAL_YEAR"
+	NextFiscalYear DateLiteral = "NEXT_FISCAL_YEAR"
+)
+
+const (
+	soqlDateFormat     = "2006-01-02"
+	soqlDateTimeFormat = "2006-01-02T15:04:05Z"
+)
+
+var (
+	UnsupportedSOQLValueError = errors.New("Unsupported SOQL value type")
+	EmptySOQLFieldsError      = errors.New("SOQL query without fields")
+	EmptySOQLGroupError       = errors.New("SOQL condition group without conditions")
+)
+
+type SOQLOrder string
+
+type SOQLNulls string
+
+// DateLiteral is a SOQL relative date such as TODAY or LAST_N_DAYS:30
+type DateLiteral string
+
+// Date is written as a SOQL date (YYYY-MM-DD) instead of a datetime
+type Date struct {
+	time.Time
+}
+
+// SOQLCondition is a WHERE or HAVING expression of a SOQLQuery
+type SOQLCondition interface {
+	writeSOQL(sb *strings.Builder) error
+}
+
+// SOQLQuery is a fluent SOQL builder. Values are escaped when the query is built,
+// so user input can be passed straight to the condition constructors.
+//
+//	q := NewSOQLQuery("Account").
+//		Select("Id", "Name").
+//		Where(And(Eq("Type", "Customer"), Or(Gt("AnnualRevenue", 1000000), Like("Name", "Acme%")))).
+//		OrderBy("Name", Asc).
+//		Limit(100)
+type SOQLQuery struct {
+	sobject    string
+	fields     []string
+	subqueries []*SOQLQuery
+	where      SOQLCondition
+	groupBy    []string
+	groupType  string
+	having     SOQLCondition
+	orderBy    []soqlOrderBy
+	limit      int
+	offset     int
+	forClause  string
+}
+
+type soqlOrderBy struct {
+	field string
+	order SOQLOrder
+	nulls SOQLNulls
+}
+
+type soqlComparison struct {
+	field    string
+	operator string
+	value    interface{}
+}
+
+type soqlSemiJoin struct {
+	field    string
+	operator string
+	query    *SOQLQuery
+}
+
+type soqlGroup struct {
+	operator   string
+	conditions []SOQLCondition
+}
+
+type soqlNot struct {
+	condition SOQLCondition
+}
+
+func LastNDays(n int) DateLiteral {
+	return DateLiteral(fmt.Sprintf("LAST_N_DAYS:%d", n))
+}
+
+func NextNDays(n int) DateLiteral {
+	return DateLiteral(fmt.Sprintf("NEXT_N_DAYS:%d", n))
+}
+
+func LastNMonths(n int) DateLiteral {
+	return DateLiteral(fmt.Sprintf("LAST_N_MONTHS:%d", n))
+}
+
+func NextNMonths(n int) DateLiteral {
+	return DateLiteral(fmt.Sprintf("NEXT_N_MONTHS:%d", n))
+}
+
+func DateOf(t time.Time) Date {
+	return Date{t}
+}
+
+func NewSOQLQuery(sobject string) *SOQLQuery {
+	return &SOQLQuery{sobject: sobject}
+}
+
+func (q *SOQLQuery) SObject() string {
+	return q.sobject
+}
+
+func (q *SOQLQuery) Fields() []string {
+	return q.fields
+}
+
+func (q *SOQLQuery) Select(fields ...string) *SOQLQuery {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
+// SelectSubquery adds a child relationship subquery, child is built with the relationship name as sObject
+func (q *SOQLQuery) SelectSubquery(child *SOQLQuery) *SOQLQuery {
+	q.subqueries = append(q.subqueries, child)
+	return q
+}
+
+// Where sets the WHERE expression, several conditions are joined with AND
+func (q *SOQLQuery) Where(conditions ...SOQLCondition) *SOQLQuery {
+	q.where = joinConditions(conditions)
+	return q
+}
+
+func (q *SOQLQuery) GroupBy(fields ...string) *SOQLQuery {
+	q.groupBy = append(q.groupBy, fields...)
+	return q
+}
+
+func (q *SOQLQuery) GroupByRollup(fields ...string) *SOQLQuery {
+	q.groupType = "ROLLUP"
+	return q.GroupBy(fields...)
+}
+
+func (q *SOQLQuery) GroupByCube(fields ...string) *SOQLQuery {
+	q.groupType = "CUBE"
+	return q.GroupBy(fields...)
+}
+
+// Having sets the HAVING expression, several conditions are joined with AND
+func (q *SOQLQuery) Having(conditions ...SOQLCondition) *SOQLQuery {
+	q.having = joinConditions(conditions)
+	return q
+}
+
+func (q *SOQLQuery) OrderBy(field string, order SOQLOrder) *SOQLQuery {
+	q.orderBy = append(q.orderBy, soqlOrderBy{field: field, order: order})
+	return q
+}
+
+func (q *SOQLQuery) OrderByNulls(field string, order SOQLOrder, nulls SOQLNulls) *SOQLQuery {
+	q.orderBy = append(q.orderBy, soqlOrderBy{field: field, order: order, nulls: nulls})
+	return q
+}
+
+func (q *SOQLQuery) Limit(limit int) *SOQLQuery {
+	q.limit = limit
+	return q
+}
+
+func (q *SOQLQuery) Offset(offset int) *SOQLQuery {
+	q.offset = offset
+	return q
+}
+
+func (q *SOQLQuery) ForUpdate() *SOQLQuery {
+	q.forClause = "FOR UPDATE"
+	return q
+}
+
+func (q *SOQLQuery) ForView() *SOQLQuery {
+	q.forClause = "FOR VIEW"
+	return q
+}
+
+func (q *SOQLQuery) ForReference() *SOQLQuery {
+	q.forClause = "FOR REFERENCE"
+	return q
+}
+
+// Build returns the SOQL text, unescaped for URLs
+func (q *SOQLQuery) Build() (string, error) {
+	var sb strings.Builder
+	if err := q.writeSOQL(&sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (q *SOQLQuery) String() string {
+	query, err := q.Build()
+	if err != nil {
+		return fmt.Sprintf("%%!SOQL(%v)", err)
+	}
+	return query
+}
+
+func (q *SOQLQuery) writeSOQL(sb *strings.Builder) error {
+	if len(q.fields) == 0 && len(q.subqueries) == 0 {
+		return EmptySOQLFieldsError
+	}
+
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(q.fields, ", "))
+	for i, child := range q.subqueries {
+		if i > 0 || len(q.fields) > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		if err := child.writeSOQL(sb); err != nil {
+			return fmt.Errorf("subquery %s: %w", child.sobject, err)
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(" FROM ")
+	sb.WriteString(q.sobject)
+
+	if q.where != nil {
+		sb.WriteString(" WHERE ")
+		if err := q.where.writeSOQL(sb); err != nil {
+			return err
+		}
+	}
+
+	if len(q.groupBy) > 0 {
+		sb.WriteString(" GROUP BY ")
+		if q.groupType != "" {
+			sb.WriteString(q.groupType)
+			sb.WriteString("(")
+		}
+		sb.WriteString(strings.Join(q.groupBy, ", "))
+		if q.groupType != "" {
+			sb.WriteString(")")
+		}
+	}
+
+	if q.having != nil {
+		sb.WriteString(" HAVING ")
+		if err := q.having.writeSOQL(sb); err != nil {
+			return err
+		}
+	}
+
+	q.writeOrderLimit(sb)
+
+	if q.forClause != "" {
+		sb.WriteString(" ")
+		sb.WriteString(q.forClause)
+	}
+
+	return nil
+}
+
+// writeOrderLimit writes the ORDER BY, LIMIT and OFFSET clauses, shared with SOSL RETURNING
+func (q *SOQLQuery) writeOrderLimit(sb *strings.Builder) {
+	for i, o := range q.orderBy {
+		if i == 0 {
+			sb.WriteString(" ORDER BY ")
+		} else {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(o.field)
+		if o.order != "" {
+			sb.WriteString(" ")
+			sb.WriteString(string(o.order))
+		}
+		if o.nulls != "" {
+			sb.WriteString(" ")
+			sb.WriteString(string(o.nulls))
+		}
+	}
+
+	if q.limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(strconv.Itoa(q.limit))
+	}
+
+	if q.offset > 0 {
+		sb.WriteString(" OFFSET ")
+		sb.WriteString(strconv.Itoa(q.offset))
+	}
+}
+
+func Eq(field string, value interface{}) SOQLCondition {
+	return soqlComparison{field, "=", value}
+}
+
+func Ne(field string, value interface{}) SOQLCondition {
+	return soqlComparison{field, "!=", value}
+}
+
+func Lt(field string, value interface{}) SOQLCondition {
+	return soqlComparison{field, "<", value}
+}
+
+func Le(field string, value interface{}) SOQLCondition {
+	return soqlComparison{field, "<=", value}
+}
+
+func Gt(field string, value interface{}) SOQLCondition {
+	return soqlComparison{field, ">", value}
+}
+
+func Ge(field string, value interface{}) SOQLCondition {
+	return soqlComparison{field, ">=", value}
+}
+
+// Like matches field against pattern, % and _ in pattern keep their wildcard meaning
+func Like(field, pattern string) SOQLCondition {
+	return soqlComparison{field, "LIKE", pattern}
+}
+
+// In matches field against any element of values, which must be a slice or array
+func In(field string, values interface{}) SOQLCondition {
+	return soqlComparison{field, "IN", values}
+}
+
+func NotIn(field string, values interface{}) SOQLCondition {
+	return soqlComparison{field, "NOT IN", values}
+}
+
+// Includes matches multi-select picklist fields holding any of values, which must be a slice or array
+func Includes(field string, values interface{}) SOQLCondition {
+	return soqlComparison{field, "INCLUDES", values}
+}
+
+func Excludes(field string, values interface{}) SOQLCondition {
+	return soqlComparison{field, "EXCLUDES", values}
+}
+
+// InQuery is a semi-join: field IN (SELECT ... FROM ...)
+func InQuery(field string, query *SOQLQuery) SOQLCondition {
+	return soqlSemiJoin{field, "IN", query}
+}
+
+// NotInQuery is an anti-join: field NOT IN (SELECT ... FROM ...)
+func NotInQuery(field string, query *SOQLQuery) SOQLCondition {
+	return soqlSemiJoin{field, "NOT IN", query}
+}
+
+func And(conditions ...SOQLCondition) SOQLCondition {
+	return soqlGroup{"AND", conditions}
+}
+
+func Or(conditions ...SOQLCondition) SOQLCondition {
+	return soqlGroup{"OR", conditions}
+}
+
+func Not(condition SOQLCondition) SOQLCondition {
+	return soqlNot{condition}
+}
+
+func joinConditions(conditions []SOQLCondition) SOQLCondition {
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
+	default:
+		return And(conditions...)
+	}
+}
+
+func (c soqlComparison) writeSOQL(sb *strings.Builder) error {
+	sb.WriteString(c.field)
+	sb.WriteString(" ")
+	sb.WriteString(c.operator)
+	sb.WriteString(" ")
+	switch c.operator {
+	case "IN", "NOT IN", "INCLUDES", "EXCLUDES":
+		list, err := soqlList(c.value)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.field, c.operator, err)
+		}
+		sb.WriteString(list)
+	default:
+		literal, err := SOQLLiteral(c.value)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.field, c.operator, err)
+		}
+		sb.WriteString(literal)
+	}
+	return nil
+}
+
+func (c soqlSemiJoin) writeSOQL(sb *strings.Builder) error {
+	sb.WriteString(c.field)
+	sb.WriteString(" ")
+	sb.WriteString(c.operator)
+	sb.WriteString(" (")
+	if err := c.query.writeSOQL(sb); err != nil {
+		return fmt.Errorf("%s %s: %w", c.field, c.operator, err)
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+func (g soqlGroup) writeSOQL(sb *strings.Builder) error {
+	if len(g.conditions) == 0 {
+		return fmt.Errorf("%w: %s", EmptySOQLGroupError, g.operator)
+	}
+	for i, condition := range g.conditions {
+		if i > 0 {
+			sb.WriteString(" ")
+			sb.WriteString(g.operator)
+			sb.WriteString(" ")
+		}
+		_, nested := condition.(soqlGroup)
+		if nested {
+			sb.WriteString("(")
+		}
+		if err := condition.writeSOQL(sb); err != nil {
+			return err
+		}
+		if nested {
+			sb.WriteString(")")
+		}
+	}
+	return nil
+}
+
+func (n soqlNot) writeSOQL(sb *strings.Builder) error {
+	sb.WriteString("(NOT ")
+	if err := n.condition.writeSOQL(sb); err != nil {
+		return err
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+// EscapeSOQL escapes s to be used inside a quoted SOQL string literal
+func EscapeSOQL(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\'':
+			sb.WriteString(`\'`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// SOQLLiteral formats value as a SOQL literal: strings are quoted and escaped, time.Time is
+// written as a UTC datetime, Date as a date and DateLiteral unquoted
+func SOQLLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return "'" + EscapeSOQL(v) + "'", nil
+	case DateLiteral:
+		return string(v), nil
+	case Date:
+		return v.Format(soqlDateFormat), nil
+	case *Date:
+		if v == nil {
+			return "null", nil
+		}
+		return v.Format(soqlDateFormat), nil
+	case time.Time:
+		return v.UTC().Format(soqlDateTimeFormat), nil
+	case *time.Time:
+		if v == nil {
+			return "null", nil
+		}
+		return v.UTC().Format(soqlDateTimeFormat), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	// numeric kinds come first so types like time.Duration are not quoted through String
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+
+	if v, ok := value.(fmt.Stringer); ok {
+		return "'" + EscapeSOQL(v.String()) + "'", nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return "'" + EscapeSOQL(rv.String()) + "'", nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "null", nil
+		}
+		return SOQLLiteral(rv.Elem().Interface())
+	}
+
+	return "", fmt.Errorf("%w: %T", UnsupportedSOQLValueError, value)
+}
+
+func soqlList(values interface{}) (string, error) {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("%w: %T is not a slice", UnsupportedSOQLValueError, values)
+	}
+	if rv.Len() == 0 {
+		return "", errors.New("empty value list")
+	}
+	items := make([]string, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		literal, err := SOQLLiteral(rv.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		items[i] = literal
+	}
+	return "(" + strings.Join(items, ", ") + ")", nil
+}
+
+// whereFromMap converts the legacy map filters into AND-joined equality and IN conditions,
+// sorted by field name so the generated query is stable
+func whereFromMap(where map[string]interface{}) []SOQLCondition {
+	keys := make([]string, 0, len(where))
+	for k := range where {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	conditions := make([]SOQLCondition, 0, len(keys))
+	for _, k := range keys {
+		v := where[k]
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Slice, reflect.Array:
+			conditions = append(conditions, In(k, v))
+		default:
+			conditions = append(conditions, Eq(k, v))
+		}
+	}
+	return conditions
+}
@@ -0,0 +1,131 @@
+package gforce
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEscapeSOQL(t *testing.T) {
+	type escapeItem struct {
+		input  string
+		output string
+	}
+	table := []escapeItem{
+		{input: "Acme", output: "Acme"},
+		{input: "O'Brien", output: `O\'Brien`},
+		{input: `say "hi"`, output: `say \"hi\"`},
+		{input: `C:\temp`, output: `C:\\temp`},
+		{input: "a\nb\tc\r", output: `a\nb\tc\r`},
+		{input: "' OR Name != '", output: `\' OR Name != \'`},
+		{input: "São Paulo", output: "São Paulo"},
+	}
+	for _, tt := range table {
+		if output := EscapeSOQL(tt.input); output != tt.output {
+			t.Errorf("escape '%v': '%v', expected '%v'", tt.input, output, tt.output)
+		}
+	}
+}
+
+type literalStringer string
+
+func (e literalStringer) String() string {
+	return string(e)
+}
+
+func TestSOQLLiteral(t *testing.T) {
+	type literalItem struct {
+		value   interface{}
+		literal string
+		err     error
+	}
+	var nilTime *time.Time
+	day := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("BRT", -3*60*60))
+	table := []literalItem{
+		{value: nil, literal: "null"},
+		{value: "it's", literal: `'it\'s'`},
+		{value: 42, literal: "42"},
+		{value: uint8(7), literal: "7"},
+		{value: 1.5, literal: "1.5"},
+		{value: true, literal: "true"},
+		{value: day, literal: "2021-03-04T08:06:07Z"},
+		{value: &day, literal: "2021-03-04T08:06:07Z"},
+		{value: nilTime, literal: "null"},
+		{value: DateOf(day), literal: "2021-03-04"},
+		{value: Today, literal: "TODAY"},
+		{value: LastNDays(30), literal: "LAST_N_DAYS:30"},
+		{value: 90 * time.Second, literal: "90000000000"},
+		{value: literalStringer("it's"), literal: `'it\'s'`},
+		{value: struct{}{}, err: UnsupportedSOQLValueError},
+	}
+	for _, tt := range table {
+		literal, err := SOQLLiteral(tt.value)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("literal of '%v' error '%v', expected '%v'", tt.value, err, tt.err)
+		}
+		if literal != tt.literal {
+			t.Errorf("literal of '%v': '%v', expected '%v'", tt.value, literal, tt.literal)
+		}
+	}
+}
+
+func TestSOQLQueryBuild(t *testing.T) {
+	type buildItem struct {
+		query *SOQLQuery
+		soql  string
+		err   error
+	}
+	table := []buildItem{
+		{
+			query: NewSOQLQuery("Account").Select("Id", "Name"),
+			soql:  "SELECT Id, Name FROM Account",
+		},
+		{
+			query: NewSOQLQuery("Account").Select("Id").
+				Where(And(Eq("Type", "Customer"), Or(Gt("AnnualRevenue", 1000000), Like("Name", "Acme%")))).
+				OrderBy("Name", Asc).
+				Limit(100).
+				Offset(10),
+			soql: "SELECT Id FROM Account WHERE Type = 'Customer' AND (AnnualRevenue > 1000000 OR Name LIKE 'Acme%') ORDER BY Name ASC LIMIT 100 OFFSET 10",
+		},
+		{
+			query: NewSOQLQuery("Contact").Select("Id").Where(Eq("LastName", "O'Brien"), In("Id", []string{"a", "b'"})),
+			soql:  `SELECT Id FROM Contact WHERE LastName = 'O\'Brien' AND Id IN ('a', 'b\'')`,
+		},
+		{
+			query: NewSOQLQuery("Account").Select("Id").
+				SelectSubquery(NewSOQLQuery("Contacts").Select("Id")).
+				Where(InQuery("Id", NewSOQLQuery("Opportunity").Select("AccountId").Where(Eq("IsWon", true)))),
+			soql: "SELECT Id, (SELECT Id FROM Contacts) FROM Account WHERE Id IN (SELECT AccountId FROM Opportunity WHERE IsWon = true)",
+		},
+		{
+			query: NewSOQLQuery("Opportunity").Select("StageName", "COUNT(Id)").GroupBy("StageName").Having(Gt("COUNT(Id)", 1)),
+			soql:  "SELECT StageName, COUNT(Id) FROM Opportunity GROUP BY StageName HAVING COUNT(Id) > 1",
+		},
+		{
+			query: NewSOQLQuery("Account"),
+			err:   EmptySOQLFieldsError,
+		},
+		{
+			query: NewSOQLQuery("Account").Select("Id").Where(And()),
+			err:   EmptySOQLGroupError,
+		},
+		{
+			query: NewSOQLQuery("Account").Select("Id").Where(And(Eq("Type", "Customer"), Or())),
+			err:   EmptySOQLGroupError,
+		},
+		{
+			query: NewSOQLQuery("Account").Select("Id").Where(Eq("Name", struct{}{})),
+			err:   UnsupportedSOQLValueError,
+		},
+	}
+	for _, tt := range table {
+		soql, err := tt.query.Build()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("build error '%v', expected '%v'", err, tt.err)
+		}
+		if soql != tt.soql && tt.err == nil {
+			t.Errorf("invalid query '%v', expected '%v'", soql, tt.soql)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	SearchAllFields     SOSLScope = "ALL FIELDS"
+	SearchNameFields    SOSLScope = "NAME FIELDS"
+	SearchEmailFields   SOSLScope = "EMAIL FIELDS"
+	SearchPhoneFields   SOSLScope = "PHONE FIELDS"
+	SearchSidebarFields SOSLScope = "SIDEBAR FIELDS"
+)
+
+var EmptySOSLTermError = errors.New("SOSL search term is empty")
+
+// SOSLScope is the IN clause of a SOSL search
+type SOSLScope string
+
+// SOSLSearch is a fluent SOSL builder. The term is escaped unless it is set with FindRaw,
+// RETURNING clauses reuse SOQLQuery for fields, WHERE, ORDER BY, LIMIT and OFFSET.
+//
+//	s := NewSOSLSearch("Acme").
+//		In(SearchNameFields).
+//		Returning(NewSOQLQuery("Account").Select("Id", "Name").Where(Eq("Type", "Customer")).Limit(10)).
+//		Returning(NewSOQLQuery("Contact").Select("Id", "Email")).
+//		WithSnippet(120).
+//		Limit(50)
+type SOSLSearch struct {
+	term      string
+	scope     SOSLScope
+	returning []*SOQLQuery
+	with      []string
+	limit     int
+}
+
+// SearchResult holds the records found by a search, in relevance order
+type SearchResult struct {
+	SearchRecords        []ForceRecord          `json:"searchRecords"`
+	SearchRecordMetadata []SearchRecordMetadata `json:"searchRecordMetadata,omitempty"`
+}
+
+// SearchRecordMetadata carries the snippet and spell correction data requested with WITH clauses
+type SearchRecordMetadata struct {
+	SearchPromoted bool                   `json:"searchPromoted"`
+	Snippet        map[string]interface{} `json:"snippet,omitempty"`
+	SpellCorrected bool                   `json:"spellCorrected"`
+}
+
+// ParameterizedSearch is the body of a parameterized search request
+type ParameterizedSearch struct {
+	Q               string               `json:"q"`
+	In              string               `json:"in,omitempty"`
+	Fields          []string             `json:"fields,omitempty"`
+	SObjects        []SearchSObject      `json:"sobjects,omitempty"`
+	OverallLimit    int                  `json:"overallLimit,omitempty"`
+	DefaultLimit    int                  `json:"defaultLimit,omitempty"`
+	Offset          int                  `json:"offset,omitempty"`
+	Division        string               `json:"division,omitempty"`
+	NetworkIds      []string             `json:"netWorkIds,omitempty"`
+	PricebookId     string               `json:"pricebookId,omitempty"`
+	SpellCorrection *bool                `json:"spellCorrection,omitempty"`
+	Snippet         *SearchSnippet       `json:"snippet,omitempty"`
+	DataCategories  []SearchDataCategory `json:"dataCategories,omitempty"`
+	Metadata        string               `json:"metadata,omitempty"`
+}
+
+// SearchSObject restricts a parameterized search to an sObject
+type SearchSObject struct {
+	Name    string   `json:"name"`
+	Fields  []string `json:"fields,omitempty"`
+	Where   string   `json:"where,omitempty"`
+	OrderBy string   `json:"orderBy,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+}
+
+type SearchSnippet struct {
+	TargetLength int `json:"targetLength"`
+}
+
+type SearchDataCategory struct {
+	GroupName  string   `json:"groupName"`
+	Operator   string   `json:"operator"`
+	Categories []string `json:"categories"`
+}
+
+// SearchSuggestions are the records suggested for a partial term
+type SearchSuggestions struct {
+	AutoSuggestResults []ForceRecord `json:"autoSuggestResults"`
+	HasMoreResults     bool          `json:"hasMoreResults"`
+}
+
+// SearchScope is an object of the search scope of the current user, in search order
+type SearchScope struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+func NewSOSLSearch(term string) *SOSLSearch {
+	return &SOSLSearch{term: EscapeSOSL(term)}
+}
+
+// FindRaw sets the term unescaped, for searches using wildcards and logical operators
+func (s *SOSLSearch) FindRaw(term string) *SOSLSearch {
+	s.term = term
+	return s
+}
+
+func (s *SOSLSearch) In(scope SOSLScope) *SOSLSearch {
+	s.scope = scope
+	return s
+}
+
+// Returning adds an object to the RETURNING clause, only the fields, WHERE, ORDER BY,
+// LIMIT and OFFSET of q are used
+func (s *SOSLSearch) Returning(q *SOQLQuery) *SOSLSearch {
+	s.returning = append(s.returning, q)
+	return s
+}
+
+// With adds a raw WITH clause such as "SECURITY_ENFORCED"
+func (s *SOSLSearch) With(clause string) *SOSLSearch {
+	s.with = append(s.with, clause)
+	return s
+}
+
+func (s *SOSLSearch) WithDivision(division string) *SOSLSearch {
+	return s.With("DIVISION = " + soqlString(division))
+}
+
+func (s *SOSLSearch) WithNetwork(networkIds ...string) *SOSLSearch {
+	if len(networkIds) == 1 {
+		return s.With("NETWORK = " + soqlString(networkIds[0]))
+	}
+	literals := make([]string, len(networkIds))
+	for i, id := range networkIds {
+		literals[i] = soqlString(id)
+	}
+	return s.With("NETWORK IN (" + strings.Join(literals, ", ") + ")")
+}
+
+func (s *SOSLSearch) WithPricebook(pricebookId string) *SOSLSearch {
+	return s.With("PricebookId = " + soqlString(pricebookId))
+}
+
+func (s *SOSLSearch) WithSnippet(targetLength int) *SOSLSearch {
+	if targetLength <= 0 {
+		return s.With("SNIPPET")
+	}
+	return s.With(fmt.Sprintf("SNIPPET (target_length=%d)", targetLength))
+}
+
+func (s *SOSLSearch) WithHighlight() *SOSLSearch {
+	return s.With("HIGHLIGHT")
+}
+
+func (s *SOSLSearch) WithSpellCorrection(enabled bool) *SOSLSearch {
+	return s.With("SPELL_CORRECTION = " + strconv.FormatBool(enabled))
+}
+
+func (s *SOSLSearch) WithMetadata(metadata string) *SOSLSearch {
+	return s.With("METADATA = " + soqlString(metadata))
+}
+
+func (s *SOSLSearch) Limit(limit int) *SOSLSearch {
+	s.limit = limit
+	return s
+}
+
+// Build returns the SOSL text, unescaped for URLs
+func (s *SOSLSearch) Build() (string, error) {
+	if strings.TrimSpace(s.term) == "" {
+		return "", EmptySOSLTermError
+	}
+
+	var sb strings.Builder
+	sb.WriteString("FIND {")
+	sb.WriteString(s.term)
+	sb.WriteString("}")
+
+	if s.scope != "" {
+		sb.WriteString(" IN ")
+		sb.WriteString(string(s.scope))
+	}
+
+	for i, q := range s.returning {
+		if i == 0 {
+			sb.WriteString(" RETURNING ")
+		} else {
+			sb.WriteString(", ")
+		}
+		if err := q.writeReturning(&sb); err != nil {
+			return "", fmt.Errorf("returning %s: %w", q.sobject, err)
+		}
+	}
+
+	for _, clause := range s.with {
+		sb.WriteString(" WITH ")
+		sb.WriteString(clause)
+	}
+
+	if s.limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(strconv.Itoa(s.limit))
+	}
+
+	return sb.String(), nil
+}
+
+func (s *SOSLSearch) String() string {
+	search, err := s.Build()
+	if err != nil {
+		return fmt.Sprintf("%%!SOSL(%v)", err)
+	}
+	return search
+}
+
+func (q *SOQLQuery) writeReturning(sb *strings.Builder) error {
+	sb.WriteString(q.sobject)
+	if len(q.fields) == 0 {
+		return nil
+	}
+	sb.WriteString("(")
+	sb.WriteString(strings.Join(q.fields, ", "))
+	if q.where != nil {
+		sb.WriteString(" WHERE ")
+		if err := q.where.writeSOQL(sb); err != nil {
+			return err
+		}
+	}
+	q.writeOrderLimit(sb)
+	sb.WriteString(")")
+	return nil
+}
+
+// EscapeSOSL escapes the reserved characters of a SOSL search term
+func EscapeSOSL(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '?', '&', '|', '!', '{', '}', '[', ']', '(', ')', '^', '~', '*', ':', '\\', '"', '\'', '+', '-':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// soqlString quotes and escapes s as a string literal
+func soqlString(s string) string {
+	return "'" + EscapeSOQL(s) + "'"
+}
+
+// BySObject groups the records by the sObject type of their attributes, keeping relevance order
+func (r SearchResult) BySObject() map[string][]ForceRecord {
+	groups := map[string][]ForceRecord{}
+	for _, record := range r.SearchRecords {
+		sobject := ""
+		if attributes, ok := record["attributes"].(map[string]interface{}); ok {
+			sobject, _ = attributes["type"].(string)
+		}
+		groups[sobject] = append(groups[sobject], record)
+	}
+	return groups
+}
+
+// Search runs a SOSL search
+func (f *Force) Search(search string) (result SearchResult, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/search?q=%s", f.Credentials.InstanceUrl, apiVersion, url.QueryEscape(search))
+	body, err := f.httpGet(url, false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding search result: %w", err)
+	}
+	return
+}
+
+// SearchSOSL builds s and runs it with Search
+func (f *Force) SearchSOSL(s *SOSLSearch) (result SearchResult, err error) {
+	search, err := s.Build()
+	if err != nil {
+		return
+	}
+	return f.Search(search)
+}
+
+// ParameterizedSearch runs a search described as JSON, with no SOSL escaping involved
+func (f *Force) ParameterizedSearch(search ParameterizedSearch) (result SearchResult, err error) {
+	if strings.TrimSpace(search.Q) == "" {
+		err = EmptySOSLTermError
+		return
+	}
+	url := fmt.Sprintf("%s/services/data/%s/parameterizedSearch", f.Credentials.InstanceUrl, apiVersion)
+	data, err := json.Marshal(search)
+	if err != nil {
+		return
+	}
+	body, err := f.httpPostJSON(url, string(data), false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding search result: %w", err)
+	}
+	return
+}
+
+// SearchSuggestions returns records whose name matches the partial term, fields are extra
+// fields returned for each suggestion
+func (f *Force) SearchSuggestions(term, sobject string, limit int, fields ...string) (result SearchSuggestions, err error) {
+	params := url.Values{}
+	params.Set("q", term)
+	params.Set("sobject", sobject)
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if len(fields) > 0 {
+		params.Set("fields", strings.Join(fields, ","))
+	}
+	url := fmt.Sprintf("%s/services/data/%s/search/suggestions?%s", f.Credentials.InstanceUrl, apiVersion, params.Encode())
+	body, err := f.httpGet(url, false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding search suggestions: %w", err)
+	}
+	return
+}
+
+// SearchScopeOrder returns the objects searched by the current user, in the order they are searched
+func (f *Force) SearchScopeOrder() (scopes []SearchScope, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/search/scopeOrder", f.Credentials.InstanceUrl, apiVersion)
+	body, err := f.httpGet(url, false)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &scopes); err != nil {
+		err = fmt.Errorf("Error decoding search scope order: %w", err)
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package gforce
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEscapeSOSL(t *testing.T) {
+	type escapeItem struct {
+		input  string
+		output string
+	}
+	table := []escapeItem{
+		{input: "Acme", output: "Acme"},
+		{input: "Acme & Co", output: `Acme \& Co`},
+		{input: "a-b+c", output: `a\-b\+c`},
+		{input: "{find}", output: `\{find\}`},
+		{input: `O'Brien "Jr"`, output: `O\'Brien \"Jr\"`},
+		{input: `C:\temp`, output: `C\:\\temp`},
+		{input: "why? (not)!", output: `why\? \(not\)\!`},
+		{input: "wild*card~[x]|^", output: `wild\*card\~\[x\]\|\^`},
+	}
+	for _, tt := range table {
+		if output := EscapeSOSL(tt.input); output != tt.output {
+			t.Errorf("escape '%v': '%v', expected '%v'", tt.input, output, tt.output)
+		}
+	}
+}
+
+func TestSOSLSearchBuild(t *testing.T) {
+	type buildItem struct {
+		search *SOSLSearch
+		sosl   string
+		err    error
+	}
+	table := []buildItem{
+		{
+			search: NewSOSLSearch("Acme & Co").
+				In(SearchNameFields).
+				Returning(NewSOQLQuery("Account").Select("Id", "Name").Where(Eq("Type", "Customer")).Limit(5)).
+				Returning(NewSOQLQuery("Contact").Select("Id")).
+				WithDivision("O'Neil").
+				Limit(10),
+			sosl: `FIND {Acme \& Co} IN NAME FIELDS RETURNING Account(Id, Name WHERE Type = 'Customer' LIMIT 5), Contact(Id) WITH DIVISION = 'O\'Neil' LIMIT 10`,
+		},
+		{
+			search: NewSOSLSearch("").FindRaw("acme* AND NOT test").WithNetwork("a", "b"),
+			sosl:   `FIND {acme* AND NOT test} WITH NETWORK IN ('a', 'b')`,
+		},
+		{
+			search: NewSOSLSearch("  "),
+			err:    EmptySOSLTermError,
+		},
+	}
+	for _, tt := range table {
+		sosl, err := tt.search.Build()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("build error '%v', expected '%v'", err, tt.err)
+		}
+		if sosl != tt.sosl {
+			t.Errorf("invalid search '%v', expected '%v'", sosl, tt.sosl)
+		}
+	}
+}
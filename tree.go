@@ -0,0 +1,328 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	maxTreeRecords = 200
+	maxTreeLevels  = 5
+)
+
+var TreeLimitError = errors.New("sObject tree limit exceeded")
+
+// TreeRecord is a record to insert with its children, keyed by child relationship name
+// (e.g. Contacts). A ReferenceId is generated when empty.
+type TreeRecord struct {
+	SObject     string
+	ReferenceId string
+	Fields      ForceRecord
+	Children    map[string][]TreeRecord
+}
+
+// TreeOptions controls InsertTree
+type TreeOptions struct {
+	// ParentFields maps a child relationship to its lookup field (e.g. Contacts: AccountId).
+	// Trees over 200 records or 5 levels are only split when every relationship they use is
+	// mapped: the children that do not fit are inserted by later requests with the lookup set
+	// to the created parent.
+	ParentFields map[string]string
+}
+
+type treeResult struct {
+	HasErrors bool `json:"hasErrors"`
+	Results   []struct {
+		ReferenceId string `json:"referenceId"`
+		Id          string `json:"id"`
+		Errors      []struct {
+			StatusCode string   `json:"statusCode"`
+			Message    string   `json:"message"`
+			Fields     []string `json:"fields"`
+		} `json:"errors"`
+	} `json:"results"`
+}
+
+// detachedChildren are children moved out of a request, to be linked to their parent once created
+type detachedChildren struct {
+	parentRef    string
+	relationship string
+	records      []TreeRecord
+}
+
+// InsertTree inserts records of sobject with their children through /composite/tree and
+// returns the Id created for each reference id. Each request is atomic; when a tree has to be
+// split, requests already sent are not rolled back and their Ids are returned with the error.
+func (f *Force) InsertTree(sobject string, records []TreeRecord, options TreeOptions) (ids map[string]string, err error) {
+	ids = map[string]string{}
+	seen := map[string]bool{}
+	counter := 0
+	records = copyTrees(records)
+	for i := range records {
+		if records[i].SObject == "" {
+			records[i].SObject = sobject
+		}
+		if records[i].SObject != sobject {
+			return ids, fmt.Errorf("record %d is a %s, expected %s", i, records[i].SObject, sobject)
+		}
+		if err = assignReferenceIds(&records[i], seen, &counter); err != nil {
+			return
+		}
+	}
+
+	for _, record := range records {
+		if treeSize(record) > maxTreeRecords || treeDepth(record) > maxTreeLevels {
+			if err = checkParentFields(record, options); err != nil {
+				return
+			}
+		}
+	}
+
+	err = f.insertTrees(sobject, records, options, ids)
+	return
+}
+
+func (f *Force) insertTrees(sobject string, records []TreeRecord, options TreeOptions, ids map[string]string) error {
+	var (
+		batch    []TreeRecord
+		detached []detachedChildren
+		budget   = maxTreeRecords
+	)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := f.postTree(sobject, batch, ids); err != nil {
+			return err
+		}
+		batch, budget = nil, maxTreeRecords
+		return nil
+	}
+
+	for _, record := range records {
+		if budget < maxTreeRecords && treeSize(record) > budget {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, trimTree(record, 1, &budget, &detached))
+		if budget == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	for _, group := range detached {
+		parentId, ok := ids[group.parentRef]
+		if !ok {
+			return fmt.Errorf("No Id created for %s", group.parentRef)
+		}
+		bySObject := map[string][]TreeRecord{}
+		var order []string
+		for _, child := range group.records {
+			if child.Fields == nil {
+				child.Fields = ForceRecord{}
+			} else {
+				child.Fields = copyRecord(child.Fields)
+			}
+			child.Fields[options.ParentFields[group.relationship]] = parentId
+			if _, ok := bySObject[child.SObject]; !ok {
+				order = append(order, child.SObject)
+			}
+			bySObject[child.SObject] = append(bySObject[child.SObject], child)
+		}
+		for _, childSObject := range order {
+			if err := f.insertTrees(childSObject, bySObject[childSObject], options, ids); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Force) postTree(sobject string, records []TreeRecord, ids map[string]string) error {
+	payload := make([]ForceRecord, len(records))
+	for i, record := range records {
+		payload[i] = treePayload(record)
+	}
+	data, err := json.Marshal(map[string][]ForceRecord{"records": payload})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/services/data/%s/composite/tree/%s", f.Credentials.InstanceUrl, apiVersion, sobject)
+	body, err := f.httpPostJSON(url, string(data), false)
+
+	var result treeResult
+	if e := json.Unmarshal(body, &result); e != nil || len(result.Results) == 0 {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("Error decoding tree response: %w", e)
+	}
+	if !result.HasErrors {
+		for _, r := range result.Results {
+			ids[r.ReferenceId] = r.Id
+		}
+		return nil
+	}
+
+	var treeErr error
+	for _, r := range result.Results {
+		e := &SubrequestError{ReferenceId: r.ReferenceId}
+		for _, item := range r.Errors {
+			e.Errors = append(e.Errors, ForceError{Message: item.Message, ErrorCode: item.StatusCode, Fields: item.Fields})
+		}
+		treeErr = multierr.Append(treeErr, e)
+	}
+	return treeErr
+}
+
+func treePayload(record TreeRecord) ForceRecord {
+	payload := make(ForceRecord, len(record.Fields)+len(record.Children)+1)
+	for k, v := range record.Fields {
+		payload[k] = v
+	}
+	payload["attributes"] = map[string]interface{}{"type": record.SObject, "referenceId": record.ReferenceId}
+	for relationship, children := range record.Children {
+		nested := make([]ForceRecord, len(children))
+		for i, child := range children {
+			nested[i] = treePayload(child)
+		}
+		payload[relationship] = map[string]interface{}{"records": nested}
+	}
+	return payload
+}
+
+// trimTree copies record with as many descendants as budget and the level limit allow,
+// the others are appended to detached. The record itself takes one unit of budget.
+func trimTree(record TreeRecord, level int, budget *int, detached *[]detachedChildren) TreeRecord {
+	*budget--
+	trimmed := record
+	trimmed.Children = nil
+	for _, relationship := range sortedRelationships(record) {
+		var kept, moved []TreeRecord
+		for _, child := range record.Children[relationship] {
+			if level < maxTreeLevels && *budget > 0 && (len(moved) == 0) {
+				kept = append(kept, trimTree(child, level+1, budget, detached))
+			} else {
+				moved = append(moved, child)
+			}
+		}
+		if len(kept) > 0 {
+			if trimmed.Children == nil {
+				trimmed.Children = map[string][]TreeRecord{}
+			}
+			trimmed.Children[relationship] = kept
+		}
+		if len(moved) > 0 {
+			*detached = append(*detached, detachedChildren{parentRef: record.ReferenceId, relationship: relationship, records: moved})
+		}
+	}
+	return trimmed
+}
+
+func assignReferenceIds(record *TreeRecord, seen map[string]bool, counter *int) error {
+	if record.ReferenceId == "" {
+		for {
+			*counter++
+			record.ReferenceId = fmt.Sprintf("ref%d", *counter)
+			if !seen[record.ReferenceId] {
+				break
+			}
+		}
+	}
+	if seen[record.ReferenceId] {
+		return fmt.Errorf("%w: %s", DuplicateReferenceIdError, record.ReferenceId)
+	}
+	seen[record.ReferenceId] = true
+	for _, relationship := range sortedRelationships(*record) {
+		children := record.Children[relationship]
+		for i := range children {
+			if children[i].SObject == "" {
+				return fmt.Errorf("child %d of %s in %s has no SObject", i, record.ReferenceId, relationship)
+			}
+			if err := assignReferenceIds(&children[i], seen, counter); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkParentFields(record TreeRecord, options TreeOptions) error {
+	for relationship, children := range record.Children {
+		if options.ParentFields[relationship] == "" {
+			return fmt.Errorf("%w: %s has %d records over %d levels and no parent field for %s",
+				TreeLimitError, record.ReferenceId, treeSize(record), treeDepth(record), relationship)
+		}
+		for _, child := range children {
+			if err := checkParentFields(child, options); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func treeSize(record TreeRecord) int {
+	size := 1
+	for _, children := range record.Children {
+		for _, child := range children {
+			size += treeSize(child)
+		}
+	}
+	return size
+}
+
+func treeDepth(record TreeRecord) int {
+	depth := 0
+	for _, children := range record.Children {
+		for _, child := range children {
+			if d := treeDepth(child); d > depth {
+				depth = d
+			}
+		}
+	}
+	return depth + 1
+}
+
+func sortedRelationships(record TreeRecord) []string {
+	relationships := make([]string, 0, len(record.Children))
+	for relationship := range record.Children {
+		relationships = append(relationships, relationship)
+	}
+	sort.Strings(relationships)
+	return relationships
+}
+
+// copyTrees copies the tree structure so that generated reference ids do not leak to the caller
+func copyTrees(records []TreeRecord) []TreeRecord {
+	copied := make([]TreeRecord, len(records))
+	for i, record := range records {
+		copied[i] = record
+		if record.Children != nil {
+			copied[i].Children = make(map[string][]TreeRecord, len(record.Children))
+			for relationship, children := range record.Children {
+				copied[i].Children[relationship] = copyTrees(children)
+			}
+		}
+	}
+	return copied
+}
+
+func copyRecord(record ForceRecord) ForceRecord {
+	copied := make(ForceRecord, len(record))
+	for k, v := range record {
+		copied[k] = v
+	}
+	return copied
+}
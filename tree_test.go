@@ -0,0 +1,103 @@
+package gforce
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testTree(ref string, children, depth int) TreeRecord {
+	record := TreeRecord{SObject: "Account", ReferenceId: ref, Fields: ForceRecord{"Name": ref}}
+	if depth > 1 {
+		record.Children = map[string][]TreeRecord{}
+		for i := 0; i < children; i++ {
+			record.Children["ChildAccounts"] = append(record.Children["ChildAccounts"], testTree(fmt.Sprintf("%s_%d", ref, i), children, depth-1))
+		}
+	}
+	return record
+}
+
+func TestTrimTree(t *testing.T) {
+	type trimItem struct {
+		record   TreeRecord
+		budget   int
+		size     int
+		depth    int
+		left     int
+		detached []string
+	}
+	table := []trimItem{
+		{
+			record: testTree("a", 3, 2),
+			budget: maxTreeRecords,
+			size:   4,
+			depth:  2,
+			left:   maxTreeRecords - 4,
+		},
+		{
+			record:   testTree("a", 3, 2),
+			budget:   3,
+			size:     3,
+			depth:    2,
+			left:     0,
+			detached: []string{"a ChildAccounts 1"},
+		},
+		{
+			record:   testTree("a", 2, 3),
+			budget:   4,
+			size:     4,
+			depth:    3,
+			left:     0,
+			detached: []string{"a ChildAccounts 1"},
+		},
+		{
+			record:   testTree("a", 2, 3),
+			budget:   3,
+			size:     3,
+			depth:    3,
+			left:     0,
+			detached: []string{"a_0 ChildAccounts 1", "a ChildAccounts 1"},
+		},
+		{
+			record:   testTree("a", 1, maxTreeLevels+2),
+			budget:   maxTreeRecords,
+			size:     maxTreeLevels,
+			depth:    maxTreeLevels,
+			left:     maxTreeRecords - maxTreeLevels,
+			detached: []string{"a_0_0_0_0 ChildAccounts 1"},
+		},
+	}
+	for i, tt := range table {
+		var detached []detachedChildren
+		budget := tt.budget
+		trimmed := trimTree(tt.record, 1, &budget, &detached)
+		if size := treeSize(trimmed); size != tt.size {
+			t.Errorf("%d: invalid size %d, expected %d", i, size, tt.size)
+		}
+		if depth := treeDepth(trimmed); depth != tt.depth {
+			t.Errorf("%d: invalid depth %d, expected %d", i, depth, tt.depth)
+		}
+		if budget != tt.left {
+			t.Errorf("%d: invalid budget left %d, expected %d", i, budget, tt.left)
+		}
+		var moved []string
+		for _, d := range detached {
+			moved = append(moved, fmt.Sprintf("%s %s %d", d.parentRef, d.relationship, len(d.records)))
+		}
+		if !reflect.DeepEqual(moved, tt.detached) {
+			t.Errorf("%d: invalid detached '%v', expected '%v'", i, moved, tt.detached)
+		}
+		if treeSize(tt.record) != tt.size+detachedSize(detached) {
+			t.Errorf("%d: records lost while trimming", i)
+		}
+	}
+}
+
+func detachedSize(detached []detachedChildren) (size int) {
+	for _, d := range detached {
+		for _, record := range d.records {
+			size += treeSize(record)
+		}
+	}
+	return
+}
@@ -0,0 +1,287 @@
+package gforce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// largest blobs Salesforce accepts: 2 GB for ContentVersion and 500 MB for the other objects
+// through a multipart insert, with lower limits on the Body of Attachment and Document
+const (
+	maxContentVersionSize = 2 << 30
+	maxUploadSize         = 500 << 20
+	maxAttachmentSize     = 25 << 20
+	maxDocumentSize       = 5 << 20
+)
+
+var UploadTooLargeError = errors.New("Upload over the size limit")
+
+// UploadOptions controls the multipart uploads
+type UploadOptions struct {
+	// Size is the length of the content, sent as Content-Length when set, otherwise the
+	// request body is chunked
+	Size int64
+	// ContentType of the file, application/octet-stream by default
+	ContentType string
+	// Progress is called as the content is sent with the bytes sent so far and Size
+	Progress func(sent, total int64)
+	// LinkedEntityIds are the records a ContentVersion is shared with through ContentDocumentLink
+	LinkedEntityIds []string
+	// ShareType of the links, V (viewer) by default
+	ShareType string
+	// Visibility of the links, AllUsers by default
+	Visibility string
+}
+
+// UploadResult is the outcome of an upload. ContentDocumentId and LinkIds are only set for
+// ContentVersion; a link that failed is reported by the error with LinkIds left empty.
+type UploadResult struct {
+	Id                string
+	ContentDocumentId string
+	LinkIds           []string
+}
+
+// UploadContentVersion inserts a ContentVersion from content, fields holds Title and the
+// other ContentVersion fields; PathOnClient defaults to filename.
+//
+//	file, _ := os.Open("report.pdf")
+//	info, _ := file.Stat()
+//	result, err := f.UploadContentVersion(ctx, ForceRecord{"Title": "Report"}, "report.pdf", file,
+//		UploadOptions{Size: info.Size(), LinkedEntityIds: []string{accountId}})
+func (f *Force) UploadContentVersion(ctx context.Context, fields ForceRecord, filename string, content io.Reader, options UploadOptions) (result UploadResult, err error) {
+	fields = copyRecord(fields)
+	if fields["PathOnClient"] == nil {
+		fields["PathOnClient"] = filename
+	}
+	if result.Id, err = f.UploadBlob(ctx, "ContentVersion", "VersionData", fields, filename, content, options); err != nil {
+		return
+	}
+
+	url := fmt.Sprintf("%s/services/data/%s/sobjects/ContentVersion/%s?fields=ContentDocumentId", f.Credentials.InstanceUrl, apiVersion, result.Id)
+	body, err := f.httpGetContext(ctx, url, false)
+	if err != nil {
+		return
+	}
+	var version struct{ ContentDocumentId string }
+	if err = json.Unmarshal(body, &version); err != nil {
+		err = fmt.Errorf("Error decoding content version: %w", err)
+		return
+	}
+	result.ContentDocumentId = version.ContentDocumentId
+
+	if len(options.LinkedEntityIds) > 0 {
+		result.LinkIds, err = f.LinkContentDocument(ctx, result.ContentDocumentId, options.LinkedEntityIds, options.ShareType, options.Visibility)
+	}
+	return
+}
+
+// UploadAttachment inserts an Attachment of parentId from content
+func (f *Force) UploadAttachment(ctx context.Context, parentId, name string, content io.Reader, options UploadOptions) (result UploadResult, err error) {
+	fields := ForceRecord{"ParentId": parentId, "Name": name}
+	if options.ContentType != "" {
+		fields["ContentType"] = options.ContentType
+	}
+	result.Id, err = f.UploadBlob(ctx, "Attachment", "Body", fields, name, content, options)
+	return
+}
+
+// UploadDocument inserts a Document in folderId from content
+func (f *Force) UploadDocument(ctx context.Context, folderId, name string, content io.Reader, options UploadOptions) (result UploadResult, err error) {
+	fields := ForceRecord{"FolderId": folderId, "Name": name}
+	if options.ContentType != "" {
+		fields["ContentType"] = options.ContentType
+	}
+	result.Id, err = f.UploadBlob(ctx, "Document", "Body", fields, name, content, options)
+	return
+}
+
+// LinkContentDocument shares a ContentDocument with records and returns the ContentDocumentLink
+// Ids in the order of linkedEntityIds
+func (f *Force) LinkContentDocument(ctx context.Context, contentDocumentId string, linkedEntityIds []string, shareType, visibility string) (ids []string, err error) {
+	if shareType == "" {
+		shareType = "V"
+	}
+	if visibility == "" {
+		visibility = "AllUsers"
+	}
+	links := make([]ForceRecord, len(linkedEntityIds))
+	for i, linkedEntityId := range linkedEntityIds {
+		links[i] = ForceRecord{
+			"ContentDocumentId": contentDocumentId,
+			"LinkedEntityId":    linkedEntityId,
+			"ShareType":         shareType,
+			"Visibility":        visibility,
+		}
+	}
+	results, err := f.CreateRecords(ctx, "ContentDocumentLink", links, CollectionOptions{AllOrNone: true})
+	if err != nil {
+		return
+	}
+	for i, result := range results {
+		if e := result.Err(); e != nil {
+			return nil, fmt.Errorf("link to %s: %w", linkedEntityIds[i], e)
+		}
+		ids = append(ids, result.Id)
+	}
+	return
+}
+
+// UploadBlob inserts a record of sobject with fields and blobField read from content, as a
+// multipart/form-data request that streams content instead of base64 encoding it in memory.
+// A content that is not an io.Seeker cannot be sent again, so an expired session is returned
+// as SessionExpiredError instead of being refreshed and retried.
+func (f *Force) UploadBlob(ctx context.Context, sobject, blobField string, fields ForceRecord, filename string, content io.Reader, options UploadOptions) (id string, err error) {
+	limit := uploadLimit(sobject)
+	if options.Size > limit {
+		return "", fmt.Errorf("%w: %d bytes, %s accepts %d", UploadTooLargeError, options.Size, sobject, limit)
+	}
+	entity, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	contentType := options.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, entityPartName(sobject)))
+	header.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return
+	}
+	if _, err = part.Write(entity); err != nil {
+		return
+	}
+	header = textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, blobField, escapeQuotes(filename)))
+	header.Set("Content-Type", contentType)
+	if _, err = writer.CreatePart(header); err != nil {
+		return
+	}
+	prefix := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err = writer.Close(); err != nil {
+		return
+	}
+	suffix := buf.Bytes()
+
+	url := fmt.Sprintf("%s/services/data/%s/sobjects/%s", f.Credentials.InstanceUrl, apiVersion, sobject)
+	send := func() (*http.Response, error) {
+		counted := &progressReader{reader: content, total: options.Size, limit: limit, progress: options.Progress}
+		req, err := httpRequest("POST", url, io.MultiReader(bytes.NewReader(prefix), counted, bytes.NewReader(suffix)))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		if options.Size > 0 {
+			req.ContentLength = int64(len(prefix)) + options.Size + int64(len(suffix))
+		}
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", f.Credentials.AccessToken))
+		req.Header.Add("Content-Type", writer.FormDataContentType())
+		return doRequest(req)
+	}
+
+	// a retry rewinds the content to where it started, not to the beginning of the file
+	seeker, seekable := content.(io.Seeker)
+	var start int64
+	if seekable {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	res, err := send()
+	if err == nil && res.StatusCode == 401 {
+		res.Body.Close()
+		if !seekable || f.Credentials.RefreshToken == "" {
+			return "", SessionExpiredError
+		}
+		if err = f.RefreshSession(); err != nil {
+			return
+		}
+		if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return
+		}
+		res, err = send()
+	}
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 401 {
+		return "", SessionExpiredError
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	if res.StatusCode/100 != 2 {
+		return "", newSubrequestError("", res.StatusCode, body)
+	}
+	var result ForceCreateRecordResult
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding upload result: %w", err)
+		return
+	}
+	id = result.Id
+	return
+}
+
+// uploadLimit returns the largest content accepted for sobject
+func uploadLimit(sobject string) int64 {
+	switch sobject {
+	case "ContentVersion":
+		return maxContentVersionSize
+	case "Attachment":
+		return maxAttachmentSize
+	case "Document":
+		return maxDocumentSize
+	}
+	return maxUploadSize
+}
+
+// entityPartName is the name of the part holding the fields, entity_content for ContentVersion
+func entityPartName(sobject string) string {
+	if sobject == "ContentVersion" {
+		return "entity_content"
+	}
+	return "entity_" + strings.ToLower(sobject)
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// progressReader reports the bytes read and fails past the upload limit
+type progressReader struct {
+	reader   io.Reader
+	sent     int64
+	total    int64
+	limit    int64
+	progress func(sent, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.sent += int64(n)
+	if r.sent > r.limit {
+		return n, fmt.Errorf("%w: over %d bytes read", UploadTooLargeError, r.sent)
+	}
+	if n > 0 && r.progress != nil {
+		r.progress(r.sent, r.total)
+	}
+	return
+}
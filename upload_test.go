@@ -0,0 +1,172 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type uploadPart struct {
+	name        string
+	filename    string
+	contentType string
+	content     string
+}
+
+// readUploadParts returns the parts of a multipart/form-data request
+func readUploadParts(t *testing.T, r *http.Request) (parts []uploadPart) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Errorf("content type '%v', expected multipart/form-data", r.Header.Get("Content-Type"))
+		return
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Errorf("multipart error '%v'", err)
+			return
+		}
+		content, _ := ioutil.ReadAll(part)
+		parts = append(parts, uploadPart{part.FormName(), part.FileName(), part.Header.Get("Content-Type"), string(content)})
+	}
+}
+
+func TestUploadAttachment(t *testing.T) {
+	var (
+		path  string
+		parts []uploadPart
+	)
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		parts = readUploadParts(t, r)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"00P000000000001AAA","success":true,"errors":[]}`)
+	})
+
+	var progress []int64
+	result, err := f.UploadAttachment(context.Background(), "001000000000001AAA", `notes "v2".txt`, strings.NewReader("hello world"), UploadOptions{
+		Size:        11,
+		ContentType: "text/plain",
+		Progress: func(sent, total int64) {
+			progress = append(progress, sent)
+		},
+	})
+	if err != nil {
+		t.Fatalf("upload error '%v'", err)
+	}
+	if result.Id != "00P000000000001AAA" {
+		t.Errorf("id '%v', expected '%v'", result.Id, "00P000000000001AAA")
+	}
+	if expected := "/services/data/" + apiVersion + "/sobjects/Attachment"; path != expected {
+		t.Errorf("path '%v', expected '%v'", path, expected)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 11 {
+		t.Errorf("progress '%v', expected to end at 11", progress)
+	}
+
+	if len(parts) != 2 {
+		t.Fatalf("parts '%+v', expected the entity and the body", parts)
+	}
+	var entity map[string]string
+	if err := json.Unmarshal([]byte(parts[0].content), &entity); err != nil {
+		t.Fatalf("entity '%v': %v", parts[0].content, err)
+	}
+	expectedEntity := map[string]string{"ParentId": "001000000000001AAA", "Name": `notes "v2".txt`, "ContentType": "text/plain"}
+	if parts[0].name != "entity_attachment" || parts[0].contentType != "application/json" || !reflect.DeepEqual(entity, expectedEntity) {
+		t.Errorf("entity part '%+v', expected '%v'", parts[0], expectedEntity)
+	}
+	expectedBody := uploadPart{name: "Body", filename: `notes "v2".txt`, contentType: "text/plain", content: "hello world"}
+	if parts[1] != expectedBody {
+		t.Errorf("body part '%+v', expected '%+v'", parts[1], expectedBody)
+	}
+}
+
+func TestUploadBlobRetry(t *testing.T) {
+	var contents []string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/oauth2/token") {
+			fmt.Fprintf(w, `{"access_token":"refreshed","instance_url":"http://%s"}`, r.Host)
+			return
+		}
+		parts := readUploadParts(t, r)
+		if len(parts) == 2 {
+			contents = append(contents, parts[1].content)
+		}
+		if r.Header.Get("Authorization") != "Bearer refreshed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `[{"errorCode":"INVALID_SESSION_ID","message":"Session expired or invalid"}]`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"068000000000001AAA","success":true,"errors":[]}`)
+	})
+	f.Credentials.RefreshToken = "refresh"
+	f.Credentials.ForceEndpoint = EndpointInstace
+	f.Credentials.SessionOptions = &SessionOptions{RefreshMethod: RefreshOauth}
+
+	// the caller already consumed a header, the retry sends the same bytes as the first attempt
+	content := strings.NewReader("header|payload")
+	io.CopyN(ioutil.Discard, content, int64(len("header|")))
+	id, err := f.UploadBlob(context.Background(), "Document", "Body", ForceRecord{"Name": "payload"}, "payload.bin", content, UploadOptions{})
+	if err != nil {
+		t.Fatalf("upload error '%v'", err)
+	}
+	if id != "068000000000001AAA" {
+		t.Errorf("id '%v', expected '%v'", id, "068000000000001AAA")
+	}
+	if expected := []string{"payload", "payload"}; !reflect.DeepEqual(contents, expected) {
+		t.Errorf("sent contents '%v', expected '%v'", contents, expected)
+	}
+
+	// without io.Seeker the content cannot be sent again
+	f.Credentials.AccessToken = "expired"
+	_, err = f.UploadBlob(context.Background(), "Document", "Body", ForceRecord{"Name": "payload"}, "payload.bin", io.MultiReader(strings.NewReader("payload")), UploadOptions{})
+	if err != SessionExpiredError {
+		t.Errorf("error '%v', expected '%v'", err, SessionExpiredError)
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request sent over the upload limit")
+	})
+	type limitItem struct {
+		sobject string
+		size    int64
+		err     error
+	}
+	table := []limitItem{
+		{sobject: "Attachment", size: 25<<20 + 1, err: UploadTooLargeError},
+		{sobject: "Document", size: 5<<20 + 1, err: UploadTooLargeError},
+		{sobject: "ContentVersion", size: 2<<30 + 1, err: UploadTooLargeError},
+		{sobject: "Account", size: 500<<20 + 1, err: UploadTooLargeError},
+	}
+	for _, tt := range table {
+		_, err := f.UploadBlob(context.Background(), tt.sobject, "Body", ForceRecord{}, "file", strings.NewReader(""), UploadOptions{Size: tt.size})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s of %d bytes error '%v', expected '%v'", tt.sobject, tt.size, err, tt.err)
+		}
+	}
+
+	// content without a Size fails once more than the limit is read
+	f = newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	})
+	_, err := f.UploadBlob(context.Background(), "Document", "Body", ForceRecord{}, "file", strings.NewReader(strings.Repeat("x", 5<<20+1)), UploadOptions{})
+	if !errors.Is(err, UploadTooLargeError) {
+		t.Errorf("error '%v', expected '%v'", err, UploadTooLargeError)
+	}
+}
@@ -0,0 +1,88 @@
+package gforce
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// UpsertResult is the outcome of an upsert. Id is empty when Salesforce answers 204 to an
+// update, as API versions before 46.0 do.
+type UpsertResult struct {
+	Id         string
+	Created    bool
+	StatusCode int
+}
+
+// MultipleMatchesError is returned when the external id matches several records (HTTP 300),
+// Ids lists them
+type MultipleMatchesError struct {
+	SObject         string
+	ExternalIdField string
+	ExternalId      string
+	Ids             []string
+}
+
+func (e *MultipleMatchesError) Error() string {
+	return fmt.Sprintf("%s %s %s matches %d records: %s", e.SObject, e.ExternalIdField, e.ExternalId, len(e.Ids), strings.Join(e.Ids, ", "))
+}
+
+// UpsertSObject creates or updates the record of sobject whose externalIdField is externalId,
+// from a map or a struct (see EncodeRecord). Errors are *SubrequestError, which unwrap to
+// EntityIsDeleted, DuplicateValueError..., or *MultipleMatchesError.
+func (f *Force) UpsertSObject(sobject, externalIdField, externalId string, record interface{}) (result UpsertResult, err error) {
+	values, err := EncodeRecord(record)
+	if err != nil {
+		return
+	}
+	delete(values, "Id")
+	delete(values, externalIdField)
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	return f.UpsertRecordResult(sobject, externalIdField, externalId, string(data))
+}
+
+// UpsertRecordResult is UpsertRecordJSON reporting whether the record was created
+func (f *Force) UpsertRecordResult(sobject, externalIdField, externalId, data string) (result UpsertResult, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/sobjects/%s/%s/%s", f.Credentials.InstanceUrl, apiVersion, sobject, externalIdField, url.PathEscape(externalId))
+	res, body, err := f.httpSendJSON("PATCH", url, data, nil, false)
+	if err != nil {
+		return
+	}
+	result.StatusCode = res.StatusCode
+
+	switch {
+	case res.StatusCode == http.StatusMultipleChoices:
+		err = newMultipleMatchesError(sobject, externalIdField, externalId, body)
+	case res.StatusCode/100 != 2:
+		err = newSubrequestError("", res.StatusCode, body)
+	case res.StatusCode == http.StatusNoContent:
+	default:
+		var created ForceCreateRecordResult
+		if e := json.Unmarshal(body, &created); e != nil {
+			err = fmt.Errorf("Error decoding upsert result: %w", e)
+			return
+		}
+		result.Id = created.Id
+		result.Created = res.StatusCode == http.StatusCreated
+	}
+	return
+}
+
+// newMultipleMatchesError reads the record urls of a 300 response
+func newMultipleMatchesError(sobject, externalIdField, externalId string, body []byte) error {
+	var urls []string
+	if err := json.Unmarshal(body, &urls); err != nil {
+		return fmt.Errorf("Error decoding multiple matches: %w", err)
+	}
+	e := &MultipleMatchesError{SObject: sobject, ExternalIdField: externalIdField, ExternalId: externalId}
+	for _, u := range urls {
+		e.Ids = append(e.Ids, path.Base(u))
+	}
+	return e
+}
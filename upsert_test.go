@@ -0,0 +1,80 @@
+package gforce
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestUpsertSObject(t *testing.T) {
+	type upsertItem struct {
+		status  int
+		body    string
+		result  UpsertResult
+		matches []string
+		err     error
+	}
+	table := []upsertItem{
+		{
+			status: http.StatusCreated,
+			body:   `{"id":"001000000000001AAA","success":true,"errors":[],"created":true}`,
+			result: UpsertResult{Id: "001000000000001AAA", Created: true, StatusCode: http.StatusCreated},
+		},
+		{
+			status: http.StatusOK,
+			body:   `{"id":"001000000000001AAA","success":true,"errors":[],"created":false}`,
+			result: UpsertResult{Id: "001000000000001AAA", StatusCode: http.StatusOK},
+		},
+		{
+			status: http.StatusNoContent,
+			result: UpsertResult{StatusCode: http.StatusNoContent},
+		},
+		{
+			status:  http.StatusMultipleChoices,
+			body:    `["/services/data/v50.0/sobjects/Account/001000000000001AAA","/services/data/v50.0/sobjects/Account/001000000000002AAA"]`,
+			result:  UpsertResult{StatusCode: http.StatusMultipleChoices},
+			matches: []string{"001000000000001AAA", "001000000000002AAA"},
+		},
+		{
+			status: http.StatusBadRequest,
+			body:   `[{"errorCode":"DUPLICATE_VALUE","message":"duplicate value found"}]`,
+			result: UpsertResult{StatusCode: http.StatusBadRequest},
+			err:    DuplicateValueError,
+		},
+	}
+	for _, tt := range table {
+		var method, path, data string
+		f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			method, path, data = r.Method, r.URL.EscapedPath(), string(body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+
+		result, err := f.UpsertSObject("Account", "External__c", "A/1", map[string]interface{}{"Name": "Acme", "External__c": "A/1", "Id": "x"})
+		if expected := "/services/data/" + apiVersion + "/sobjects/Account/External__c/A%2F1"; method != "PATCH" || path != expected {
+			t.Errorf("request '%v %v', expected 'PATCH %v'", method, path, expected)
+		}
+		if expected := `{"Name":"Acme"}`; data != expected {
+			t.Errorf("request body '%v', expected '%v'", data, expected)
+		}
+		if result != tt.result {
+			t.Errorf("%d: result '%+v', expected '%+v'", tt.status, result, tt.result)
+		}
+
+		if tt.matches != nil {
+			var matchesErr *MultipleMatchesError
+			if !errors.As(err, &matchesErr) || !reflect.DeepEqual(matchesErr.Ids, tt.matches) {
+				t.Errorf("%d: error '%v', expected matches '%v'", tt.status, err, tt.matches)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%d: error '%v', expected '%v'", tt.status, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const salesforceDateTimeFormat = "2006-01-02T15:04:05.000Z"
+
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+var (
+	UnsupportedFieldValueError = errors.New("Unsupported field value")
+	InvalidDecimalError        = errors.New("Invalid decimal")
+)
+
+// Decimal is a number written as is, for currency and percent fields that must not go
+// through float64
+type Decimal string
+
+// MarshalJSON writes d as a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if !decimalPattern.MatchString(string(d)) {
+		return nil, fmt.Errorf("%w: %q", InvalidDecimalError, string(d))
+	}
+	return []byte(d), nil
+}
+
+// MarshalJSON writes a Date as YYYY-MM-DD, the zero Date as null
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + d.Format(soqlDateFormat) + `"`), nil
+}
+
+// UnmarshalJSON reads a Date from YYYY-MM-DD or an RFC 3339 datetime, null leaves it unchanged
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if t, err := time.Parse(soqlDateFormat, s); err == nil {
+		d.Time = t
+		return nil
+	}
+	return d.Time.UnmarshalJSON(data)
+}
+
+// EncodeRecord converts a map or a struct into the field values of a REST write.
+//
+// Struct fields are named like in DecodeRecord (soql tag, json tag, field name); parent and
+// child relationships, dotted paths and fields tagged readonly (soql:"CreatedDate,readonly")
+// are skipped, nil pointers and slices and zero values tagged omitempty are left out. In maps
+// and structs, time.Time is written as a datetime, Date as a date, []string as a multi-select
+// picklist and nil as null, which clears the field; zero times and dates are left out, clearing
+// them takes fieldsToNull, which are set to null.
+func EncodeRecord(record interface{}, fieldsToNull ...string) (values ForceRecord, err error) {
+	values = ForceRecord{}
+	rv := reflect.ValueOf(record)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("%w: nil record", UnsupportedFieldValueError)
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %v keys", UnsupportedFieldValueError, rv.Type().Key())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			if name == "attributes" || isZeroTime(iter.Value()) {
+				continue
+			}
+			if values[name], err = fieldValue(iter.Value()); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	case reflect.Struct:
+		if err = encodeStruct(rv, values); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %T", UnsupportedFieldValueError, record)
+	}
+
+	for _, name := range fieldsToNull {
+		values[name] = nil
+	}
+	return
+}
+
+func encodeStruct(rv reflect.Value, values ForceRecord) (err error) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := fieldName(sf)
+		if name == "-" || strings.Contains(name, ".") {
+			continue
+		}
+		options := tagOptions(sf)
+		if options["readonly"] {
+			continue
+		}
+
+		fv := rv.Field(i)
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType && ft != dateType {
+			continue
+		}
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct {
+			continue
+		}
+		if (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Slice) && fv.IsNil() {
+			continue
+		}
+		if options["omitempty"] && reflect.Indirect(fv).IsZero() || isZeroTime(fv) {
+			continue
+		}
+		if values[name], err = fieldValue(fv); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return
+}
+
+// tagOptions returns the options after the name of the soql tag, or of the json tag without one
+func tagOptions(sf reflect.StructField) map[string]bool {
+	tag, ok := sf.Tag.Lookup("soql")
+	if !ok {
+		tag = sf.Tag.Get("json")
+	}
+	options := map[string]bool{}
+	for _, option := range strings.Split(tag, ",")[1:] {
+		options[strings.TrimSpace(option)] = true
+	}
+	return options
+}
+
+// isZeroTime reports a zero time.Time or Date, which is never meant as a value
+func isZeroTime(v reflect.Value) bool {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return false
+	}
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.IsZero()
+	case Date:
+		return value.IsZero()
+	}
+	return false
+}
+
+// fieldValue converts a value to its JSON representation for a write
+func fieldValue(v reflect.Value) (interface{}, error) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.UTC().Format(salesforceDateTimeFormat), nil
+	case Date, Decimal, json.Number, json.RawMessage:
+		return value, nil
+	case json.Marshaler:
+		return value, nil
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		// multi-select picklist
+		items := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			if item.Kind() == reflect.Interface {
+				item = item.Elem()
+			}
+			if item.Kind() != reflect.String {
+				return nil, fmt.Errorf("%w: %v in a multi-select picklist", UnsupportedFieldValueError, item.Type())
+			}
+			items[i] = item.String()
+		}
+		return strings.Join(items, ";"), nil
+	case reflect.Map:
+		// nested values such as address or geolocation compound fields are sent as is
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("%w: %v", UnsupportedFieldValueError, v.Type())
+}
+
+// CreateSObject creates a record from a map or a struct, see EncodeRecord
+func (f *Force) CreateSObject(sobject string, record interface{}) (id string, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/sobjects/%s", f.Credentials.InstanceUrl, apiVersion, sobject)
+	return f.createEncoded(url, record)
+}
+
+// CreateToolingSObject creates a Tooling API record from a map or a struct, see EncodeRecord
+func (f *Force) CreateToolingSObject(sobject string, record interface{}) (id string, err error) {
+	url := fmt.Sprintf("%s/services/data/%s/tooling/sobjects/%s", f.Credentials.InstanceUrl, apiVersion, sobject)
+	return f.createEncoded(url, record)
+}
+
+// UpdateSObject updates a record from a map or a struct and clears fieldsToNull, see EncodeRecord.
+// id is either a record Id or externalIdField:value, as in UpdateRecord.
+func (f *Force) UpdateSObject(sobject, id string, record interface{}, fieldsToNull ...string) (err error) {
+	values, err := EncodeRecord(record, fieldsToNull...)
+	if err != nil {
+		return
+	}
+	delete(values, "Id")
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	_, err = f.httpPatchJSON(recordURL(f, sobject, id), string(data), false)
+	return
+}
+
+func (f *Force) createEncoded(url string, record interface{}) (id string, err error) {
+	values, err := EncodeRecord(record)
+	if err != nil {
+		return
+	}
+	delete(values, "Id")
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	body, err := f.httpPostJSON(url, string(data), false)
+	if err != nil {
+		return
+	}
+	var result ForceCreateRecordResult
+	if err = json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("Error decoding create result: %w", err)
+		return
+	}
+	id = result.Id
+	return
+}
+
+// recordURL addresses a record by Id, or by external Id when id is externalIdField:value
+func recordURL(f *Force, sobject, id string) string {
+	fields := strings.SplitN(id, ":", 2)
+	if len(fields) == 1 {
+		return fmt.Sprintf("%s/services/data/%s/sobjects/%s/%s", f.Credentials.InstanceUrl, apiVersion, sobject, id)
+	}
+	return fmt.Sprintf("%s/services/data/%s/sobjects/%s/%s/%s", f.Credentials.InstanceUrl, apiVersion, sobject, fields[0], url.PathEscape(fields[1]))
+}
@@ -0,0 +1,147 @@
+package gforce
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type encodeTestAccount struct {
+	Id          string
+	Name        string    `soql:"Name"`
+	Website     string    `json:"Website,omitempty"`
+	Revenue     Decimal   `soql:"AnnualRevenue,omitempty"`
+	Founded     Date      `soql:"Founded__c"`
+	Reviewed    time.Time `soql:"Reviewed__c"`
+	Regions     []string  `soql:"Regions__c"`
+	Rating      *int      `soql:"Rating__c"`
+	CreatedDate time.Time `soql:"CreatedDate,readonly"`
+	OwnerName   string    `soql:"Owner.Name"`
+	Parent      *encodeTestAccount
+	Internal    string `soql:"-"`
+}
+
+func TestEncodeRecord(t *testing.T) {
+	reviewed := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("BRT", -3*60*60))
+	type encodeItem struct {
+		record       interface{}
+		fieldsToNull []string
+		json         string
+		err          error
+	}
+	table := []encodeItem{
+		{
+			record: encodeTestAccount{
+				Id:          "001A",
+				Name:        "Acme",
+				Revenue:     "1234567890.12",
+				Founded:     DateOf(time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)),
+				Reviewed:    reviewed,
+				Regions:     []string{"South", "North"},
+				CreatedDate: reviewed,
+				OwnerName:   "Ana",
+				Parent:      &encodeTestAccount{Name: "Holding"},
+				Internal:    "x",
+			},
+			json: `{"AnnualRevenue":1234567890.12,"Founded__c":"1990-05-17","Id":"001A","Name":"Acme","Regions__c":"South;North","Reviewed__c":"2021-03-04T08:06:07.000Z"}`,
+		},
+		{
+			record:       &encodeTestAccount{Name: "Acme", Website: "acme.com"},
+			fieldsToNull: []string{"Rating__c"},
+			json:         `{"Id":"","Name":"Acme","Rating__c":null,"Website":"acme.com"}`,
+		},
+		{
+			record: map[string]interface{}{"attributes": map[string]interface{}{"type": "Account"}, "Name": "Acme", "Description": nil, "Tags__c": []interface{}{"a", "b"}},
+			json:   `{"Description":null,"Name":"Acme","Tags__c":"a;b"}`,
+		},
+		{
+			record:       map[string]interface{}{"Name": "Acme", "Reviewed__c": time.Time{}, "Founded__c": Date{}, "Closed__c": &time.Time{}},
+			fieldsToNull: []string{"Founded__c"},
+			json:         `{"Founded__c":null,"Name":"Acme"}`,
+		},
+		{
+			record: map[string]interface{}{"Founded__c": DateOf(time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)), "Reviewed__c": reviewed},
+			json:   `{"Founded__c":"1990-05-17","Reviewed__c":"2021-03-04T08:06:07.000Z"}`,
+		},
+		{
+			record: map[string]interface{}{"Amount": Decimal("1e5")},
+			err:    InvalidDecimalError,
+		},
+		{
+			record: map[string]interface{}{"Tags__c": []int{1}},
+			err:    UnsupportedFieldValueError,
+		},
+		{
+			record: 42,
+			err:    UnsupportedFieldValueError,
+		},
+	}
+	for i, tt := range table {
+		values, err := EncodeRecord(tt.record, tt.fieldsToNull...)
+		var data []byte
+		if err == nil {
+			data, err = json.Marshal(values)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%d: encode error '%v', expected '%v'", i, err, tt.err)
+			continue
+		}
+		if tt.err == nil && string(data) != tt.json {
+			t.Errorf("%d: invalid values '%s', expected '%s'", i, data, tt.json)
+		}
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	type dateItem struct {
+		json string
+		date Date
+		err  bool
+	}
+	table := []dateItem{
+		{json: `"1990-05-17"`, date: DateOf(time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC))},
+		{json: `"1990-05-17T00:00:00Z"`, date: DateOf(time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC))},
+		{json: `null`},
+		{json: `"17/05/1990"`, err: true},
+		{json: `19900517`, err: true},
+	}
+	for _, tt := range table {
+		var date Date
+		err := json.Unmarshal([]byte(tt.json), &date)
+		if (err != nil) != tt.err {
+			t.Errorf("unmarshal '%v' error '%v'", tt.json, err)
+		}
+		if !date.Equal(tt.date.Time) {
+			t.Errorf("unmarshal '%v': '%v', expected '%v'", tt.json, date, tt.date)
+		}
+		if tt.err {
+			continue
+		}
+		data, _ := json.Marshal(date)
+		var again Date
+		if err = json.Unmarshal(data, &again); err != nil || !again.Equal(date.Time) {
+			t.Errorf("round trip of '%v': '%s', '%v'", tt.json, data, err)
+		}
+	}
+}
+
+func TestRecordURL(t *testing.T) {
+	type urlItem struct {
+		id  string
+		url string
+	}
+	f := &Force{Credentials: &ForceSession{InstanceUrl: "https://example.my.salesforce.com"}}
+	prefix := "https://example.my.salesforce.com/services/data/" + apiVersion + "/sobjects/Account/"
+	table := []urlItem{
+		{id: "001A", url: prefix + "001A"},
+		{id: "Code__c:A-1", url: prefix + "Code__c/A-1"},
+		{id: "Code__c:A/1 B?c#d", url: prefix + "Code__c/A%2F1%20B%3Fc%23d"},
+		{id: "Code__c:a:b", url: prefix + "Code__c/a:b"},
+	}
+	for _, tt := range table {
+		if url := recordURL(f, "Account", tt.id); url != tt.url {
+			t.Errorf("url of '%v': '%v', expected '%v'", tt.id, url, tt.url)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package gforce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	RecordCreated ChangeEventType = "created"
+	RecordUpdated ChangeEventType = "updated"
+	RecordDeleted ChangeEventType = "deleted"
+)
+
+const (
+	defaultWatchInterval   = time.Minute
+	defaultWatchMaxBackoff = 30 * time.Minute
+)
+
+type ChangeEventType string
+
+// ChangeEvent is a change seen by a Watcher. Commit it once it has been processed.
+type ChangeEvent struct {
+	Type    ChangeEventType
+	SObject string
+	Id      string
+	Record  ForceRecord
+	Cursor  WatchCursor
+}
+
+// WatchCursor is the SystemModstamp and Id of the last change seen on an object
+type WatchCursor struct {
+	Time time.Time `json:"time"`
+	Id   string    `json:"id"`
+}
+
+// CursorStore persists the cursor of each watched object
+type CursorStore interface {
+	// Load returns the zero cursor when sobject was never watched
+	Load(ctx context.Context, sobject string) (WatchCursor, error)
+	Save(ctx context.Context, sobject string, cursor WatchCursor) error
+}
+
+// WatchTarget is an object to poll and the fields to return in its events
+type WatchTarget struct {
+	SObject string
+	Fields  []string
+}
+
+// WatcherOptions configures a Watcher
+type WatcherOptions struct {
+	Targets []WatchTarget
+	// Interval between polls, defaults to a minute
+	Interval time.Duration
+	// Store keeps the cursors across runs, they only live in memory without it
+	Store CursorStore
+	// Start is the cursor time of objects without a stored cursor, defaults to the time Run starts
+	Start time.Time
+	// MinRemainingRequests doubles the interval, up to MaxBackoff, while DailyApiRequests is below it
+	MinRemainingRequests int64
+	MaxBackoff           time.Duration
+	// AutoCommit saves the cursor as soon as an event is received instead of on Commit
+	AutoCommit bool
+	// OnError is called with poll errors, the watcher backs off and retries
+	OnError func(error)
+}
+
+// Watcher polls objects for changes, for orgs without Change Data Capture.
+// Changes are read with queryAll ordered by SystemModstamp and Id, so records sharing a
+// timestamp are not skipped and soft deletes are reported. Delivery is at least once: the cursor
+// is only saved on Commit, and events after the saved cursor are delivered again on restart.
+//
+//	w := f.NewWatcher(WatcherOptions{Targets: []WatchTarget{{SObject: "Account", Fields: []string{"Name"}}}, Store: store})
+//	go w.Run(ctx)
+//	for event := range w.Events() {
+//		process(event)
+//		w.Commit(ctx, event)
+//	}
+type Watcher struct {
+	force   *Force
+	options WatcherOptions
+	events  chan ChangeEvent
+	mu      sync.Mutex
+	cursors map[string]WatchCursor
+}
+
+// FileCursorStore keeps the cursors of every object in one JSON file
+type FileCursorStore struct {
+	mu   sync.Mutex
+	Path string
+}
+
+func (f *Force) NewWatcher(options WatcherOptions) *Watcher {
+	if options.Interval <= 0 {
+		options.Interval = defaultWatchInterval
+	}
+	if options.MaxBackoff < options.Interval {
+		options.MaxBackoff = defaultWatchMaxBackoff
+		if options.MaxBackoff < options.Interval {
+			options.MaxBackoff = options.Interval
+		}
+	}
+	return &Watcher{
+		force:   f,
+		options: options,
+		events:  make(chan ChangeEvent),
+		cursors: map[string]WatchCursor{},
+	}
+}
+
+// Events returns the channel of changes, closed when Run returns
+func (w *Watcher) Events() <-chan ChangeEvent {
+	return w.events
+}
+
+// Commit saves the cursor of event, changes up to it are not delivered again
+func (w *Watcher) Commit(ctx context.Context, event ChangeEvent) error {
+	if w.options.Store == nil {
+		return nil
+	}
+	return w.options.Store.Save(ctx, event.SObject, event.Cursor)
+}
+
+// Run polls until ctx is done and returns ctx.Err(), or the error of loading the cursors
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.events)
+
+	start := w.options.Start
+	if start.IsZero() {
+		start = time.Now().UTC()
+	}
+	for _, target := range w.options.Targets {
+		cursor := WatchCursor{Time: start}
+		if w.options.Store != nil {
+			stored, err := w.options.Store.Load(ctx, target.SObject)
+			if err != nil {
+				return fmt.Errorf("Store.Load(%s): %w", target.SObject, err)
+			}
+			if !stored.Time.IsZero() {
+				cursor = stored
+			}
+		}
+		w.cursors[target.SObject] = cursor
+	}
+
+	wait := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if w.options.OnError != nil {
+				w.options.OnError(err)
+			}
+			wait = w.backoff(wait)
+			continue
+		}
+		wait = w.options.Interval
+	}
+}
+
+func (w *Watcher) backoff(wait time.Duration) time.Duration {
+	if wait < w.options.Interval {
+		return w.options.Interval
+	}
+	wait *= 2
+	if wait > w.options.MaxBackoff {
+		wait = w.options.MaxBackoff
+	}
+	return wait
+}
+
+func (w *Watcher) poll(ctx context.Context) error {
+	if err := w.force.checkApiBudget(w.options.MinRemainingRequests); err != nil {
+		return err
+	}
+	for _, target := range w.options.Targets {
+		if err := w.pollTarget(ctx, target); err != nil {
+			return fmt.Errorf("%s: %w", target.SObject, err)
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) pollTarget(ctx context.Context, target WatchTarget) error {
+	w.mu.Lock()
+	cursor := w.cursors[target.SObject]
+	w.mu.Unlock()
+	// records created after the cursor of the previous poll are new, cursor moves while emitting
+	since := cursor.Time
+
+	fields := append([]string{}, target.Fields...)
+	for _, required := range []string{"Id", "SystemModstamp", "CreatedDate", "IsDeleted"} {
+		if !containsFold(fields, required) {
+			fields = append(fields, required)
+		}
+	}
+	// SOQL datetimes have second precision, the rest of the tie-break is done here
+	query, err := NewSOQLQuery(target.SObject).
+		Select(fields...).
+		Where(Ge("SystemModstamp", cursor.Time.Truncate(time.Second))).
+		OrderBy("SystemModstamp", Asc).
+		OrderBy("Id", Asc).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	it := w.force.NewQueryIterator(ctx, query, WithQueryAll, WithPrefetch)
+	defer it.Close()
+	for it.Next() {
+		record := it.Record()
+		event := ChangeEvent{SObject: target.SObject, Id: cast.ToString(record["Id"]), Record: record, Type: RecordUpdated}
+		stamp, err := ParseSalesforceTime(cast.ToString(record["SystemModstamp"]))
+		if err != nil {
+			return fmt.Errorf("SystemModstamp of %s: %w", event.Id, err)
+		}
+		event.Cursor = WatchCursor{Time: stamp, Id: event.Id}
+		if !cursor.before(event.Cursor) {
+			continue
+		}
+
+		if deleted, _ := record["IsDeleted"].(bool); deleted {
+			event.Type = RecordDeleted
+		} else if created, err := ParseSalesforceTime(cast.ToString(record["CreatedDate"])); err == nil && created.After(since) {
+			event.Type = RecordCreated
+		}
+
+		select {
+		case w.events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		cursor = event.Cursor
+		w.mu.Lock()
+		w.cursors[target.SObject] = cursor
+		w.mu.Unlock()
+		if w.options.AutoCommit {
+			if err := w.Commit(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
+
+// before orders cursors by time, then by Id for changes sharing a timestamp
+func (c WatchCursor) before(other WatchCursor) bool {
+	if c.Time.Equal(other.Time) {
+		return c.Id < other.Id
+	}
+	return c.Time.Before(other.Time)
+}
+
+func NewFileCursorStore(path string) *FileCursorStore {
+	return &FileCursorStore{Path: path}
+}
+
+func (s *FileCursorStore) read() (cursors map[string]WatchCursor, err error) {
+	cursors = map[string]WatchCursor{}
+	body, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return cursors, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &cursors)
+	return
+}
+
+func (s *FileCursorStore) Load(ctx context.Context, sobject string) (WatchCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cursors, err := s.read()
+	if err != nil {
+		return WatchCursor{}, err
+	}
+	return cursors[sobject], nil
+}
+
+func (s *FileCursorStore) Save(ctx context.Context, sobject string, cursor WatchCursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cursors, err := s.read()
+	if err != nil {
+		return err
+	}
+	cursors[sobject] = cursor
+	body, err := json.MarshalIndent(cursors, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err = ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
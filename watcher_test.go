@@ -0,0 +1,74 @@
+package gforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcherCursorTieBreak(t *testing.T) {
+	stamp := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	at := func(seconds int) string {
+		return stamp.Add(time.Duration(seconds) * time.Second).Format(salesforceDateTimeFormat)
+	}
+
+	var query string
+	f := newTestForce(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		fmt.Fprintf(w, `{"done":true,"totalSize":5,"records":[
+			{"Id":"001A","SystemModstamp":"%[1]s","CreatedDate":"%[2]s","IsDeleted":false},
+			{"Id":"001B","SystemModstamp":"%[1]s","CreatedDate":"%[2]s","IsDeleted":false},
+			{"Id":"001C","SystemModstamp":"%[1]s","CreatedDate":"%[2]s","IsDeleted":false},
+			{"Id":"001D","SystemModstamp":"%[3]s","CreatedDate":"%[3]s","IsDeleted":false},
+			{"Id":"001E","SystemModstamp":"%[3]s","CreatedDate":"%[2]s","IsDeleted":true}]}`,
+			at(0), at(-3600), at(1))
+	})
+
+	store := NewFileCursorStore(filepath.Join(t.TempDir(), "cursors.json"))
+	if err := store.Save(context.Background(), "Account", WatchCursor{Time: stamp, Id: "001B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := f.NewWatcher(WatcherOptions{
+		Targets:  []WatchTarget{{SObject: "Account"}},
+		Interval: time.Hour,
+		Store:    store,
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	var events []string
+	for event := range w.Events() {
+		events = append(events, fmt.Sprintf("%s %s", event.Type, event.Id))
+		if err := w.Commit(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		if len(events) == 3 {
+			cancel()
+		}
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error '%v', expected '%v'", err, context.Canceled)
+	}
+
+	if expected := "SELECT Id, SystemModstamp, CreatedDate, IsDeleted FROM Account WHERE SystemModstamp >= 2021-03-04T05:06:07Z ORDER BY SystemModstamp ASC, Id ASC"; query != expected {
+		t.Errorf("query '%v', expected '%v'", query, expected)
+	}
+	// 001A and 001B share the stored SystemModstamp and do not sort after its Id
+	if expected := []string{"updated 001C", "created 001D", "deleted 001E"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("events '%v', expected '%v'", events, expected)
+	}
+	cursor, _ := store.Load(context.Background(), "Account")
+	if expected := (WatchCursor{Time: stamp.Add(time.Second), Id: "001E"}); !cursor.Time.Equal(expected.Time) || cursor.Id != expected.Id {
+		t.Errorf("committed cursor '%v', expected '%v'", cursor, expected)
+	}
+}